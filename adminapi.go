@@ -0,0 +1,292 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/logger"
+)
+
+type adminAPIHandler func(r *http.Request) (interface{}, error)
+
+type adminAPIError struct {
+	Status  int
+	Message string
+}
+
+func (e adminAPIError) Error() string {
+	return e.Message
+}
+
+func newAdminAPIError(status int, message string) error {
+	return adminAPIError{Status: status, Message: message}
+}
+
+func (app *solution) runAdminAPI() error {
+	if !app.Config.AdminAPI.Enabled {
+		return nil
+	}
+
+	logger.Info("run admin API..")
+	if app.Config.AdminAPI.Token == "" {
+		return errors.New("admin API token is not set")
+	}
+
+	if app.Config.AdminAPI.Listen == "" {
+		// empty address means all interfaces
+		app.Config.AdminAPI.Listen = defaultAdminAPIListen
+	}
+
+	mux := http.NewServeMux()
+	for path, methods := range map[string]map[string]adminAPIHandler{
+		"/api/users":       {http.MethodGet: app.apiGetUsers},
+		"/api/balance":     {http.MethodGet: app.apiGetBalance},
+		"/api/history":     {http.MethodGet: app.apiGetPointsHistory},
+		"/api/online":      {http.MethodGet: app.apiGetOnline},
+		"/api/withdrawals": {http.MethodPost: app.apiWithdraw},
+		"/api/vouchers": {
+			http.MethodGet:    app.apiGetVouchers,
+			http.MethodPost:   app.apiCreateVoucher,
+			http.MethodDelete: app.apiDeleteVoucher,
+		},
+	} {
+		mux.Handle(path, app.wrapAdminAPIHandler(methods))
+	}
+
+	app.AdminAPIServer = &http.Server{
+		Addr:    app.Config.AdminAPI.Listen,
+		Handler: mux,
+	}
+	go func() {
+		if err := app.AdminAPIServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error("admin API stopped: " + err.Error())
+		}
+	}()
+	return nil
+}
+
+func (app *solution) isAdminAPITokenValid(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(app.Config.AdminAPI.Token)) == 1
+}
+
+func (app *solution) wrapAdminAPIHandler(methods map[string]adminAPIHandler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.isAdminAPITokenValid(r) {
+			writeAdminAPIResponse(w, nil, newAdminAPIError(http.StatusUnauthorized, "invalid token"))
+			return
+		}
+
+		handler, isFound := methods[r.Method]
+		if !isFound {
+			writeAdminAPIResponse(w, nil, newAdminAPIError(http.StatusMethodNotAllowed, "method not allowed"))
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, adminAPIMaxRequestSize)
+		result, err := handler(r)
+		writeAdminAPIResponse(w, result, err)
+	})
+}
+
+func writeAdminAPIResponse(w http.ResponseWriter, result interface{}, err error) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err != nil {
+		status := http.StatusInternalServerError
+		var apiErr adminAPIError
+		if errors.As(err, &apiErr) {
+			status = apiErr.Status
+		} else {
+			logger.Error(err)
+		}
+
+		w.WriteHeader(status)
+		result = map[string]string{"error": err.Error()}
+	}
+
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		logger.Error(err)
+	}
+}
+
+func getAdminAPIPubkey(r *http.Request) (string, error) {
+	pubkey := r.URL.Query().Get("pubkey")
+	if !isPubkeyValid(pubkey) {
+		return "", newAdminAPIError(http.StatusBadRequest, "invalid pubkey")
+	}
+	return pubkey, nil
+}
+
+func getAdminAPIIntParam(r *http.Request, name string, defaultValue int) (int, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return defaultValue, nil
+	}
+
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < 0 {
+		return 0, newAdminAPIError(http.StatusBadRequest, "invalid "+name)
+	}
+	return value, nil
+}
+
+func decodeAdminAPIRequest(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return newAdminAPIError(http.StatusBadRequest, "failed to decode request: "+err.Error())
+	}
+	return nil
+}
+
+func (app *solution) apiGetUsers(r *http.Request) (interface{}, error) {
+	offset, err := getAdminAPIIntParam(r, "offset", 0)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := getAdminAPIIntParam(r, "limit", adminAPIDefaultLimit)
+	if err != nil {
+		return nil, err
+	}
+	if limit > adminAPIMaxLimit {
+		limit = adminAPIMaxLimit
+	}
+
+	return app.DB.getUsers(offset, limit)
+}
+
+func (app *solution) apiGetBalance(r *http.Request) (interface{}, error) {
+	pubkey, err := getAdminAPIPubkey(r)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := app.DB.getUserDBData(pubkey)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, newAdminAPIError(http.StatusNotFound, "user not found")
+	}
+	return user, nil
+}
+
+func (app *solution) apiGetPointsHistory(r *http.Request) (interface{}, error) {
+	pubkey, err := getAdminAPIPubkey(r)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := getAdminAPIIntParam(r, "limit", adminAPIDefaultLimit)
+	if err != nil {
+		return nil, err
+	}
+	if limit > adminAPIMaxLimit {
+		limit = adminAPIMaxLimit
+	}
+
+	return app.DB.getPointsHistory(pubkey, limit)
+}
+
+type adminAPIOnlineResult struct {
+	Contacts          int      `json:"contacts"`
+	ContactsOnline    int      `json:"contacts_online"`
+	ChannelOnline     int      `json:"channel_online"`
+	ContactsInChannel int      `json:"contacts_in_channel"`
+	UsersOnline       []string `json:"users_online"`
+}
+
+func (app *solution) apiGetOnline(r *http.Request) (interface{}, error) {
+	data, err := app.getContactsData()
+	if err != nil {
+		return nil, err
+	}
+
+	result := adminAPIOnlineResult{
+		Contacts:          data.Contacts,
+		ContactsOnline:    data.ContactsOnline,
+		ChannelOnline:     data.ChannelOnline,
+		ContactsInChannel: data.ContactsInChannel,
+		UsersOnline:       []string{},
+	}
+	result.UsersOnline = append(result.UsersOnline, app.getUsersOnlinePubkeys()...)
+	return result, nil
+}
+
+func (app *solution) apiWithdraw(r *http.Request) (interface{}, error) {
+	var task struct {
+		Pubkey string  `json:"pubkey"`
+		Amount float64 `json:"amount"`
+	}
+	if err := decodeAdminAPIRequest(r, &task); err != nil {
+		return nil, err
+	}
+	if !isPubkeyValid(task.Pubkey) {
+		return nil, newAdminAPIError(http.StatusBadRequest, "invalid pubkey")
+	}
+	if task.Amount <= 0 {
+		return nil, newAdminAPIError(http.StatusBadRequest, "invalid amount")
+	}
+
+	result, err := app.withdrawUserPoints(r.Context(), task.Pubkey, task.Amount)
+	switch {
+	case errors.Is(err, errUserNotFound):
+		return nil, newAdminAPIError(http.StatusNotFound, "user not found")
+	case errors.Is(err, errNotEnoughPoints):
+		return nil, newAdminAPIError(http.StatusConflict, "not enough points")
+	}
+	return result, err
+}
+
+func (app *solution) apiGetVouchers(r *http.Request) (interface{}, error) {
+	vouchers, err := app.DB.getGameVouchers()
+	if err != nil {
+		return nil, err
+	}
+
+	batches, err := app.DB.getVoucherBatches(voucherBatchesListLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"vouchers": vouchers,
+		"batches":  batches,
+	}, nil
+}
+
+func (app *solution) apiCreateVoucher(r *http.Request) (interface{}, error) {
+	var task struct {
+		Amount float64 `json:"amount"`
+	}
+	if err := decodeAdminAPIRequest(r, &task); err != nil {
+		return nil, err
+	}
+	if task.Amount <= 0 || task.Amount > maxGameVoucherAmount {
+		return nil, newAdminAPIError(http.StatusBadRequest, "invalid amount")
+	}
+
+	code, err := app.createVoucher(task.Amount)
+	if err != nil {
+		return nil, err
+	}
+	return gameVoucher{Code: code, Amount: task.Amount}, nil
+}
+
+func (app *solution) apiDeleteVoucher(r *http.Request) (interface{}, error) {
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		return nil, newAdminAPIError(http.StatusBadRequest, "voucher code is not set")
+	}
+
+	err := app.deleteVoucher(code)
+	if errors.Is(err, errVoucherNotFound) {
+		return nil, newAdminAPIError(http.StatusNotFound, "voucher not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return map[string]bool{"deleted": true}, nil
+}
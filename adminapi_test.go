@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAdminAPIAccess(t *testing.T) {
+	app := solution{}
+	app.Config.AdminAPI.Token = "secret"
+
+	handler := app.wrapAdminAPIHandler(map[string]adminAPIHandler{
+		http.MethodGet: func(r *http.Request) (interface{}, error) {
+			return "ok", nil
+		},
+	})
+
+	cases := []struct {
+		method string
+		token  string
+		status int
+	}{
+		{http.MethodGet, "", http.StatusUnauthorized},
+		{http.MethodGet, "wrong", http.StatusUnauthorized},
+		{http.MethodPost, "secret", http.StatusMethodNotAllowed},
+		{http.MethodGet, "secret", http.StatusOK},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, "/api/users", nil)
+		r.Header.Set("Authorization", "Bearer "+c.token)
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, r)
+		if w.Code != c.status {
+			t.Fatalf("%s with token %q: expected status %v, got %v", c.method, c.token, c.status, w.Code)
+		}
+	}
+}
+
+func TestAdminAPIWithdrawValidation(t *testing.T) {
+	app := solution{}
+	app.Config.AdminAPI.Token = "secret"
+	handler := app.wrapAdminAPIHandler(map[string]adminAPIHandler{http.MethodPost: app.apiWithdraw})
+
+	pubkey := strings.Repeat("AB", 32)
+	cases := []string{
+		`{"pubkey": "short", "amount": 10}`,
+		`{"pubkey": "` + strings.Repeat("ZZ", 32) + `", "amount": 10}`,
+		`{"pubkey": "` + pubkey + `", "amount": 0}`,
+		`{"pubkey": "` + pubkey + `"`,
+	}
+	for _, body := range cases {
+		r := httptest.NewRequest(http.MethodPost, "/api/withdraw", strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected status %v, got %v", body, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+func TestIsPubkeyValid(t *testing.T) {
+	valid := []string{strings.Repeat("A1", 32), strings.Repeat("f0", 32)}
+	for _, pubkey := range valid {
+		if !isPubkeyValid(pubkey) {
+			t.Fatalf("expected valid pubkey %q", pubkey)
+		}
+	}
+
+	invalid := []string{"", "AB", strings.Repeat("A", 63), strings.Repeat("A", 65), strings.Repeat("G", 64)}
+	for _, pubkey := range invalid {
+		if isPubkeyValid(pubkey) {
+			t.Fatalf("expected invalid pubkey %q", pubkey)
+		}
+	}
+}
@@ -1,9 +1,10 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"math/rand"
 	"os"
-	"strings"
 	"time"
 
 	utopiago "github.com/Sagleft/utopialib-go"
@@ -13,14 +14,33 @@ import (
 )
 
 func newSolution() solution {
+	ctx, cancel := context.WithCancel(context.Background())
 	return solution{
+		Ctx:                       ctx,
+		cancelCtx:                 cancel,
+		ShutdownCh:                make(chan struct{}),
 		WithdrawNotifyRateLimiter: rate.New(1, limitWithdrawNotifyTimeout),
 		UsersOnline:               map[string]*onlineData{},
-		VouchersCooldown:          make(map[string]time.Time),
+		Giveaways: vouchersGiveaway{
+			Active: map[string]giveawayVoucher{},
+			Rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+		},
+		Restrictions: restrictionsCache{
+			Data: map[string]userRestriction{},
+		},
+		Languages: languagesCache{
+			Data: map[string]string{},
+		},
+		Memberships: membershipCache{
+			Data: map[string]channelMembership{},
+		},
+		Health: &healthState{
+			StartedAt: time.Now(),
+		},
 	}
 }
 
-type wsHandler func(event utopiago.WsEvent)
+type wsHandler func(ctx context.Context, event utopiago.WsEvent)
 
 func (app *solution) setupWsHandlers() error {
 	logger.Info("setup utopia ws handlers..")
@@ -29,19 +49,24 @@ func (app *solution) setupWsHandlers() error {
 		"newAuthorization":          app.onNewAuth,
 		"contactStatusNotification": app.onContactNotify,
 		"newInstantMessage":         app.onUserMessage,
-		"newOutgoingInstantMessage": func(event utopiago.WsEvent) {}, // placeholder
+		"newOutgoingInstantMessage": func(ctx context.Context, event utopiago.WsEvent) {}, // placeholder
 	}
+
+	app.Events = newEventsPool(app.Ctx, app.Config.EventsPool, app.dispatchWsEvent)
+	app.Events.run()
 	return nil
 }
 
-func onWsError(err error) {
+func (app *solution) onWsError(err error) {
 	logger.Error(err.Error())
-}
+	app.Health.setWsConnected(false)
 
-func (app *solution) runInBackground() {
-	forever := make(chan struct{})
-	// run in backround
-	<-forever
+	if app.Reconnect != nil && !app.isShuttingDown() {
+		// resubscribe, the node itself can be fine
+		app.Reconnect.request(reconnectRequest{
+			Reason: tr(catalog.DefaultLang, "reconnect.ws_error", err.Error()),
+		})
+	}
 }
 
 func main() {
@@ -56,14 +81,29 @@ func main() {
 
 	err := checkErrors(
 		app.parseConfig,
+		app.setupChannels,
+		app.initRewardRules,
+		app.initBudget,
+		app.sqlDBConnect,
 		app.initVouchers,
 		app.setupModerators,
+		app.initFraudDetector,
+		app.initRestrictions,
+		app.initLanguages,
+		app.initChannelReminders,
+		app.initNLU,
+		app.initFAQ,
+		app.setupUserCommands,
 		app.tgConnect,
 		app.runTelegramBot,
+		app.setupWsHandlers,
 		app.utopiaConnect,
+		app.runOutboundQueue,
 		app.parseArgs,
 		app.tryEnterChannel,
 		app.setupCrons,
+		app.runAdminAPI,
+		app.runMonitoringServer,
 		app.initUsersOnline,
 	)
 	if err != nil {
@@ -73,12 +113,14 @@ func main() {
 
 	printSuccess("bot initiated")
 	logger.Info("bot initiated")
-	app.runInBackground()
+	app.waitForShutdownSignal()
+	app.shutdown()
 }
 
 func (app *solution) initVouchers() error {
-	gameVoucherLength = len(app.genGameVoucher())
-	return nil
+	var err error
+	app.VoucherFormat, err = newVoucherFormat(app.Config.GameVoucherPrefix, app.Config.GameVoucherFormat)
+	return err
 }
 
 func (app *solution) setupModerators() error {
@@ -104,24 +146,10 @@ func (app *solution) isUserTelegramModerator(telegramID int64) bool {
 	return isModerator
 }
 
-func (app *solution) tryEnterChannel() error {
-	logger.Info("enter into utopia channel..")
-
-	_, err := app.Config.UtopiaCfg.JoinChannel(app.Config.ChannelID)
-	app.onUtopiaError(err)
-	return nil
-}
-
 func (app *solution) parseArgs() error {
 	logger.Info("parse args..")
 
 	for _, arg := range os.Args[1:] {
-		if arg == "notify" {
-			if err := app.sendNotifyToAllUsers(); err != nil {
-				return err
-			}
-			app.exit()
-		}
 		if arg == "testOnline" {
 			if err := app.testUserOnline(); err != nil {
 				return err
@@ -171,44 +199,3 @@ func (app *solution) testUserOnline() error {
 	logger.Info(string(contactDataBytes))
 	return nil
 }
-
-func (app *solution) sendNotifyToAllUsers() error {
-	logger.Info("send notify to all users..")
-
-	contacts, err := app.Config.UtopiaCfg.GetContacts("")
-	if err != nil {
-		return err
-	}
-
-	for _, contact := range contacts {
-		msg := "Внимание!\n" +
-			"Бот приостановлен! Для продолжения работы бота вам необходимо нажать кнопку присоединиться. После присоедиенения бот автоматически продолжит свою работу."
-
-		err := app.sendMessage(contact.Pubkey, msg)
-		if err != nil {
-			if strings.Contains(err.Error(), "Rate limit exceeded") {
-				err := app.sendMessage(contact.Pubkey, msg)
-				if err != nil {
-					return err
-				}
-			} else {
-				app.onUtopiaError(err)
-			}
-		}
-
-		msg = "C625377297CFBEAA6417FCB76DAAFC3D"
-		err = app.sendMessage(contact.Pubkey, msg)
-		if err != nil {
-			if strings.Contains(err.Error(), "Rate limit exceeded") {
-				err := app.sendMessage(contact.Pubkey, msg)
-				if err != nil {
-					return err
-				}
-			}
-		}
-
-		logger.Info("send notify to user " + contact.Nick)
-		time.Sleep(time.Second * 2)
-	}
-	return nil
-}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"strconv"
+	"time"
 
 	utopiago "github.com/Sagleft/utopialib-go"
 	"github.com/google/logger"
@@ -17,41 +19,67 @@ func (app *solution) initUsersOnline() error {
 	}
 	logger.Info("found contacts: " + strconv.Itoa(len(contacts)))
 
-	for _, contact := range contacts {
-		if isUserOnline(contact) && contact.Nick != serviceAccountName {
-			app.markUserOnline(contact.Pubkey)
-		}
-	}
+	app.resyncUsersOnline(contacts)
 	return nil
 }
 
 func (app *solution) isUserInOnlineData(pubkey string) bool {
+	app.UsersOnlineLock.RLock()
+	defer app.UsersOnlineLock.RUnlock()
+
 	_, isExists := app.UsersOnline[pubkey]
 	return isExists
 }
 
+func (app *solution) getUsersOnlinePubkeys() []string {
+	app.UsersOnlineLock.RLock()
+	defer app.UsersOnlineLock.RUnlock()
+
+	pubkeys := make([]string, 0, len(app.UsersOnline))
+	for pubkey := range app.UsersOnline {
+		pubkeys = append(pubkeys, pubkey)
+	}
+	return pubkeys
+}
+
 func (app *solution) markUserOnline(pubkey string) {
+	app.UsersOnlineLock.Lock()
+	defer app.UsersOnlineLock.Unlock()
+
 	app.UsersOnline[pubkey] = &onlineData{
 		Pubkey: pubkey,
 	}
 }
 
 func (app *solution) markUserOffline(pubkey string) {
-	//if app.isUserInOnlineData(pubkey) {
+	app.UsersOnlineLock.Lock()
+	defer app.UsersOnlineLock.Unlock()
+
 	delete(app.UsersOnline, pubkey)
-	//}
 }
 
 func (app *solution) handleWsConnected() {
 	logger.Info("ws connection established")
+	app.Health.setWsConnected(true)
 }
 
 func (app *solution) handleWsEvent(event utopiago.WsEvent) {
+	if app.isShuttingDown() {
+		return
+	}
+	if _, isHandlerFound := app.WsHandlers[event.Type]; !isHandlerFound {
+		return
+	}
+	app.Events.push(event)
+}
+
+// called by events pool worker
+func (app *solution) dispatchWsEvent(ctx context.Context, event utopiago.WsEvent) {
 	handler, isHandlerFound := app.WsHandlers[event.Type]
 	if !isHandlerFound {
 		return
 	}
-	handler(event)
+	handler(ctx, event)
 }
 
 /*
@@ -64,7 +92,7 @@ func (app *solution) handleWsEvent(event utopiago.WsEvent) {
     "type": "newAuthorization"
 }
 */
-func (app *solution) onNewAuth(event utopiago.WsEvent) {
+func (app *solution) onNewAuth(ctx context.Context, event utopiago.WsEvent) {
 	// get pubkey
 	userPubkey, err := event.GetString("pk")
 	if err != nil {
@@ -78,12 +106,23 @@ func (app *solution) onNewAuth(event utopiago.WsEvent) {
 		return
 	}
 
+	if app.isAuthRejected(userPubkey) {
+		_, err = app.Config.UtopiaCfg.RejectAuthRequest(userPubkey, "")
+		if err != nil {
+			app.onUtopiaError(fmt.Errorf("failed to reject auth: %w", err))
+			return
+		}
+		logger.Info("user " + userPubkey + " auth rejected: user is banned")
+		return
+	}
+
 	// save user pubkey
 	_, err = app.DB.getUserData(userPubkey, filterNickname(nick))
 	if err != nil {
 		logger.Error(err)
 		return
 	}
+	app.handleFraudSignals(app.Fraud.onAuth(userPubkey, nick, time.Now()))
 
 	// approve auth
 	_, err = app.Config.UtopiaCfg.AcceptAuthRequest(userPubkey, "")
@@ -94,7 +133,8 @@ func (app *solution) onNewAuth(event utopiago.WsEvent) {
 
 	logger.Info("user " + userPubkey + " auth accepted")
 	for i := 0; i < len(app.Config.WelcomeMessages); i++ {
-		err = app.sendMessage(userPubkey, app.Config.WelcomeMessages[i])
+		// auth request can come again from resync after reconnect
+		err = app.sendMessageOnce(userPubkey, app.Config.WelcomeMessages[i], messagePriorityNormal, "welcome:"+strconv.Itoa(i))
 		if err != nil {
 			app.onUtopiaError(fmt.Errorf("failed to send PM: %w", err))
 		}
@@ -112,7 +152,7 @@ func (app *solution) onNewAuth(event utopiago.WsEvent) {
     "type": "contactStatusNotification"
 }
 */
-func (app *solution) onContactNotify(event utopiago.WsEvent) {
+func (app *solution) onContactNotify(ctx context.Context, event utopiago.WsEvent) {
 	userPubkey, err := event.GetString("pk")
 	if err != nil {
 		logger.Error(err)
@@ -125,20 +165,25 @@ func (app *solution) onContactNotify(event utopiago.WsEvent) {
 		return
 	}
 
-	if isUserOnline(utopiago.ContactData{
+	isOnline := isUserOnline(utopiago.ContactData{
 		Status: int(statusCode),
-	}) {
+	})
+	wasOnline := app.isUserInOnlineData(userPubkey)
+	if isOnline {
 		logger.Info(userPubkey + " online")
 		app.markUserOnline(userPubkey)
 	} else {
 		logger.Info(userPubkey + " offline")
 		app.markUserOffline(userPubkey)
 	}
+	if isOnline && !wasOnline {
+		// away & do not disturb of online user are not logins
+		app.handleFraudSignals(app.Fraud.onUserOnline(userPubkey, time.Now()))
+	}
 
-	err = app.handleContact(handleContactTask{
-		Pubkey:           userPubkey,
-		WithPayment:      false,
-		ChannelOnlineMap: make(map[string]utopiago.ChannelContactData),
+	err = app.handleContact(ctx, handleContactTask{
+		Pubkey:      userPubkey,
+		WithPayment: false,
 	})
 	if err != nil {
 		logger.Error(err)
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/logger"
+)
+
+const (
+	budgetModePause   = "pause"   // accrual stops when the budget is spent
+	budgetModeProrate = "prorate" // accrual is reduced to spread the rest of budget till the end of day
+)
+
+// points issued today, resets at midnight in rewards timezone
+type dailyBudget struct {
+	sync.Mutex
+	VouchersLock    sync.Mutex // activations are serialized, so they can't overspend the budget together
+	Day             string
+	Issued          float64            // accruals & vouchers
+	Users           map[string]float64 // pubkey -> accrued today, with user cap only
+	IsUsersLoaded   bool               // users accruals are loaded from DB for the day
+	Factor          float64            // pro-rate factor for the current contacts check
+	TickPlanned     float64            // points before limits in the current contacts check
+	LastTickPlanned float64
+	IsProrated      bool // alert was sent today
+	IsExhausted     bool // alert was sent today
+}
+
+func (b budgetConfig) isEnabled() bool {
+	return b.DailyPoints > 0 || b.UserDailyPoints > 0
+}
+
+func (app *solution) initBudget() error {
+	cfg := &app.Config.Budget
+	if cfg.Mode == "" {
+		cfg.Mode = budgetModePause
+	}
+	if cfg.Mode != budgetModePause && cfg.Mode != budgetModeProrate {
+		return errors.New("unknown budget mode: " + cfg.Mode)
+	}
+	if cfg.DailyPoints < 0 || cfg.UserDailyPoints < 0 {
+		return errors.New("budget must not be negative")
+	}
+
+	app.Budget.reset(app.getRewardsNow().Format(journalLogsTimeFormat))
+	return nil
+}
+
+func (b *dailyBudget) reset(day string) {
+	b.Day = day
+	b.Issued = 0
+	b.Users = map[string]float64{}
+	b.IsUsersLoaded = false
+	b.Factor = 1
+	b.TickPlanned = 0
+	b.LastTickPlanned = 0
+	b.IsProrated = false
+	b.IsExhausted = false
+}
+
+func getDayStart(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+// returns pro-rate factor to spend the rest of budget evenly till the end of day
+func getBudgetFactor(remaining, lastTickPlanned float64, ticksLeft int) float64 {
+	if lastTickPlanned <= 0 {
+		return 1
+	}
+	if remaining <= 0 {
+		return 0
+	}
+	if ticksLeft < 1 {
+		ticksLeft = 1
+	}
+
+	allowed := remaining / float64(ticksLeft)
+	if allowed >= lastTickPlanned {
+		return 1
+	}
+	return allowed / lastTickPlanned
+}
+
+// called before every contacts check
+func (app *solution) startBudgetTick() {
+	cfg := app.Config.Budget
+	if !cfg.isEnabled() {
+		return
+	}
+
+	now := app.getRewardsNow()
+	day := now.Format(journalLogsTimeFormat)
+	issued, err := app.DB.getIssuedPoints(getDayStart(now).Unix())
+	if err != nil {
+		logger.Error(err)
+	}
+
+	// loaded before the lock, limitAccrual doesn't query DB
+	var users map[string]float64
+	var usersErr error
+	if cfg.UserDailyPoints > 0 {
+		users, usersErr = app.DB.getAccruedPointsByUser(day)
+		if usersErr != nil {
+			logger.Error(usersErr)
+		}
+	}
+
+	app.Budget.Lock()
+	defer app.Budget.Unlock()
+
+	if day != app.Budget.Day {
+		app.Budget.reset(day)
+	}
+	if err == nil {
+		// vouchers are activated outside of contacts check
+		app.Budget.Issued = issued
+	}
+	if users != nil && usersErr == nil {
+		app.Budget.Users = users
+		app.Budget.IsUsersLoaded = true
+	}
+	botMetrics.PointsIssuedToday.set(app.Budget.Issued)
+
+	app.Budget.LastTickPlanned = app.Budget.TickPlanned
+	app.Budget.TickPlanned = 0
+	app.Budget.Factor = 1
+	if cfg.Mode != budgetModeProrate || cfg.DailyPoints <= 0 {
+		return
+	}
+
+	dayEnd := getDayStart(now).AddDate(0, 0, 1)
+	ticksLeft := int(dayEnd.Sub(now).Seconds()) / app.getContactsCronTimeoutSeconds()
+	app.Budget.Factor = getBudgetFactor(cfg.DailyPoints-app.Budget.Issued, app.Budget.LastTickPlanned, ticksLeft)
+	if app.Budget.Factor < 1 && app.Budget.Factor > 0 && !app.Budget.IsProrated {
+		app.Budget.IsProrated = true
+		app.notifyModerators(tr(catalog.DefaultLang, "budget.prorated",
+			formatFloat(app.Budget.Issued), formatFloat(cfg.DailyPoints), formatFloat(app.Budget.Factor*100),
+		))
+	}
+}
+
+// returns points the user can get from the budget.
+// the budget is charged by chargeAccrual when the points are added
+func (app *solution) limitAccrual(pubkey string, points float64) float64 {
+	cfg := app.Config.Budget
+	if !cfg.isEnabled() {
+		return points
+	}
+
+	app.Budget.Lock()
+	defer app.Budget.Unlock()
+
+	app.Budget.TickPlanned += points
+	points *= app.Budget.Factor
+
+	if cfg.UserDailyPoints > 0 {
+		if !app.Budget.IsUsersLoaded {
+			return 0 // user cap can't be checked
+		}
+		accrued := app.Budget.Users[pubkey]
+		if points > cfg.UserDailyPoints-accrued {
+			points = cfg.UserDailyPoints - accrued
+		}
+	}
+
+	if cfg.DailyPoints > 0 && points >= cfg.DailyPoints-app.Budget.Issued {
+		points = cfg.DailyPoints - app.Budget.Issued
+		if !app.Budget.IsExhausted {
+			app.Budget.IsExhausted = true
+			app.notifyModerators(tr(catalog.DefaultLang, "budget.exhausted", formatFloat(cfg.DailyPoints)))
+		}
+	}
+	if points < 0 {
+		points = 0
+	}
+	return points
+}
+
+// charges the budget with accrued points
+func (app *solution) chargeAccrual(pubkey string, points float64) {
+	cfg := app.Config.Budget
+	if !cfg.isEnabled() {
+		return
+	}
+
+	app.Budget.Lock()
+	defer app.Budget.Unlock()
+
+	app.Budget.Issued += points
+	if cfg.UserDailyPoints > 0 {
+		app.Budget.Users[pubkey] += points
+	}
+}
+
+// returns points left in the daily budget, -1 when it is not limited
+func (app *solution) getBudgetRemaining() float64 {
+	cfg := app.Config.Budget
+	if cfg.DailyPoints <= 0 {
+		return -1
+	}
+
+	app.Budget.Lock()
+	defer app.Budget.Unlock()
+
+	if day := app.getRewardsNow().Format(journalLogsTimeFormat); day != app.Budget.Day {
+		app.Budget.reset(day)
+	}
+	if app.Budget.Issued >= cfg.DailyPoints {
+		return 0
+	}
+	return cfg.DailyPoints - app.Budget.Issued
+}
+
+// charges the budget with voucher points, user cap is for online accrual only
+func (app *solution) chargeVoucher(points float64) {
+	if !app.Config.Budget.isEnabled() {
+		return
+	}
+
+	app.Budget.Lock()
+	defer app.Budget.Unlock()
+	app.Budget.Issued += points
+}
+
+func (app *solution) getBudgetState(lang string) string {
+	switch {
+	default:
+		return tr(lang, "mod.budget_state_ok")
+	case app.Budget.IsExhausted:
+		return tr(lang, "mod.budget_state_exhausted")
+	case app.Budget.Factor < 1:
+		return tr(lang, "mod.budget_state_prorated", formatFloat(app.Budget.Factor*100))
+	}
+}
+
+func formatBudgetLimit(lang string, limit float64) string {
+	if limit <= 0 {
+		return tr(lang, "mod.budget_unlimited")
+	}
+	return formatFloat(limit)
+}
+
+// today issued points vs budget
+func (app *solution) handleBudgetRequest(lang string) ([]string, error) {
+	cfg := app.Config.Budget
+	now := app.getRewardsNow()
+	accrued, vouchers, err := app.DB.getIssuedPointsByKind(getDayStart(now).Unix())
+	if err != nil {
+		return nil, err
+	}
+
+	app.Budget.Lock()
+	defer app.Budget.Unlock()
+
+	usersAtCap := 0
+	if cfg.UserDailyPoints > 0 && app.Budget.Day == now.Format(journalLogsTimeFormat) {
+		for _, points := range app.Budget.Users {
+			if points >= cfg.UserDailyPoints {
+				usersAtCap++
+			}
+		}
+	}
+
+	remaining := tr(lang, "mod.budget_unlimited")
+	if cfg.DailyPoints > 0 {
+		left := cfg.DailyPoints - accrued - vouchers
+		if left < 0 {
+			left = 0
+		}
+		remaining = formatFloat(left)
+	}
+
+	return []string{tr(lang, "mod.budget",
+		now.Format(journalLogsTimeFormat),
+		formatFloat(accrued+vouchers), formatFloat(accrued), formatFloat(vouchers),
+		formatBudgetLimit(lang, cfg.DailyPoints), remaining,
+		formatBudgetLimit(lang, cfg.UserDailyPoints), usersAtCap,
+		cfg.Mode, app.getBudgetState(lang),
+	)}, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBudgetFactor(t *testing.T) {
+	if f := getBudgetFactor(1000, 0, 10); f != 1 {
+		t.Fatalf("first check must not be reduced, got %v", f)
+	}
+	if f := getBudgetFactor(1000, 50, 10); f != 1 {
+		t.Fatalf("enough budget, got %v", f)
+	}
+	if f := getBudgetFactor(100, 50, 10); f != 0.2 {
+		t.Fatalf("expected 0.2, got %v", f)
+	}
+	if f := getBudgetFactor(0, 50, 10); f != 0 {
+		t.Fatalf("spent budget must stop accrual, got %v", f)
+	}
+}
+
+func TestLimitAccrual(t *testing.T) {
+	app := solution{Config: config{Budget: budgetConfig{
+		DailyPoints:     10,
+		UserDailyPoints: 4,
+		Mode:            budgetModePause,
+	}}}
+	app.Budget.reset("2022-06-04")
+	if points := app.limitAccrual("alice", 2); points != 0 {
+		t.Fatalf("user cap is not loaded: expected 0 points, got %v", points)
+	}
+
+	app.Budget.Users = map[string]float64{"alice": 3}
+	app.Budget.IsUsersLoaded = true
+	app.Budget.Issued = 5
+
+	accrue := func(pubkey string, points float64) float64 {
+		points = app.limitAccrual(pubkey, points)
+		app.chargeAccrual(pubkey, points)
+		return points
+	}
+	if points := accrue("alice", 2); points != 1 {
+		t.Fatalf("user cap: expected 1 point, got %v", points)
+	}
+	if points := accrue("bob", 3); points != 3 {
+		t.Fatalf("expected 3 points, got %v", points)
+	}
+	if points := accrue("carol", 3); points != 1 || !app.Budget.IsExhausted {
+		t.Fatalf("daily budget: expected 1 point, got %v", points)
+	}
+	if points := accrue("bob", 1); points != 0 {
+		t.Fatalf("spent budget: expected 0 points, got %v", points)
+	}
+}
+
+func TestLimitAccrualWithoutCharge(t *testing.T) {
+	app := solution{Config: config{Budget: budgetConfig{
+		DailyPoints:     10,
+		UserDailyPoints: 4,
+		Mode:            budgetModePause,
+	}}}
+	app.Budget.reset("2022-06-04")
+	app.Budget.IsUsersLoaded = true
+
+	// points were not added, e.g. DB error
+	for i := 0; i < 3; i++ {
+		if points := app.limitAccrual("alice", 3); points != 3 {
+			t.Fatalf("expected 3 points, got %v", points)
+		}
+	}
+	if app.Budget.Issued != 0 || app.Budget.Users["alice"] != 0 {
+		t.Fatalf("budget must not be charged, issued %v, user %v", app.Budget.Issued, app.Budget.Users["alice"])
+	}
+
+	app.chargeAccrual("alice", 3)
+	if app.Budget.Issued != 3 || app.Budget.Users["alice"] != 3 {
+		t.Fatalf("expected charged budget, issued %v, user %v", app.Budget.Issued, app.Budget.Users["alice"])
+	}
+}
+
+func TestVoucherBudget(t *testing.T) {
+	app := solution{
+		Config:  config{Budget: budgetConfig{DailyPoints: 10, Mode: budgetModePause}},
+		Rewards: &rewardSchedule{Location: time.UTC},
+	}
+	app.Budget.reset(app.getRewardsNow().Format(journalLogsTimeFormat))
+	app.Budget.Issued = 4
+
+	if left := app.getBudgetRemaining(); left != 6 {
+		t.Fatalf("expected 6 points left, got %v", left)
+	}
+	app.chargeVoucher(6)
+	if left := app.getBudgetRemaining(); left != 0 {
+		t.Fatalf("expected spent budget, got %v", left)
+	}
+
+	// previous day budget is not counted
+	app.Budget.Day = "2022-06-04"
+	if left := app.getBudgetRemaining(); left != 10 {
+		t.Fatalf("expected new day budget, got %v", left)
+	}
+
+	app.Config.Budget.DailyPoints = 0
+	if left := app.getBudgetRemaining(); left != -1 {
+		t.Fatalf("expected unlimited budget, got %v", left)
+	}
+}
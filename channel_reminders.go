@@ -0,0 +1,157 @@
+package main
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/logger"
+)
+
+// user state for reminders about channel membership
+type channelMembership struct {
+	Pubkey         string
+	IsMember       bool
+	RemindersSent  int
+	LastReminderAt int64 // unix timestamp
+	LastResumedAt  int64 // unix timestamp of the "accrual resumed" message
+}
+
+type membershipCache struct {
+	sync.RWMutex
+	Data map[string]channelMembership // pubkey -> state, only users who were out of channel
+}
+
+func (m channelMembership) isReminderDue(cfg channelReminderConfig, now time.Time) bool {
+	if m.IsMember || m.RemindersSent >= cfg.MaxCount {
+		return false
+	}
+	return now.Unix()-m.LastReminderAt >= int64(cfg.IntervalHours)*3600
+}
+
+// the count is kept between joins, so leaving & joining again gives no new reminders
+func (m *channelMembership) resetAfterCooldown(cfg channelReminderConfig, now time.Time) {
+	if m.RemindersSent > 0 && now.Unix()-m.LastReminderAt >= int64(cfg.ResetDays)*86400 {
+		m.RemindersSent = 0
+	}
+}
+
+// user is told about resumed accrual once per reminder & not more often than reminders
+func (m channelMembership) isResumedNotifyDue(cfg channelReminderConfig, now time.Time) bool {
+	if m.LastReminderAt <= m.LastResumedAt {
+		return false
+	}
+	return now.Unix()-m.LastResumedAt >= int64(cfg.IntervalHours)*3600
+}
+
+func (app *solution) initChannelReminders() error {
+	if app.Config.ChannelReminder.IntervalHours <= 0 {
+		app.Config.ChannelReminder.IntervalHours = defaultChannelReminderIntervalHours
+	}
+	if app.Config.ChannelReminder.MaxCount <= 0 {
+		app.Config.ChannelReminder.MaxCount = defaultChannelReminderMaxCount
+	}
+	if app.Config.ChannelReminder.ResetDays <= 0 {
+		app.Config.ChannelReminder.ResetDays = defaultChannelReminderResetDays
+	}
+
+	logger.Info("load channel membership..")
+	states, err := app.DB.getChannelMemberships()
+	if err != nil {
+		return err
+	}
+
+	app.Memberships.Lock()
+	defer app.Memberships.Unlock()
+	for _, state := range states {
+		app.Memberships.Data[state.Pubkey] = state
+	}
+	return nil
+}
+
+// returns false if the user was never out of channel
+func (app *solution) getChannelMembership(pubkey string) (channelMembership, bool) {
+	app.Memberships.RLock()
+	defer app.Memberships.RUnlock()
+
+	state, isFound := app.Memberships.Data[pubkey]
+	return state, isFound
+}
+
+func (app *solution) saveChannelMembership(state channelMembership) error {
+	if err := app.DB.saveChannelMembership(state); err != nil {
+		return err
+	}
+
+	app.Memberships.Lock()
+	app.Memberships.Data[state.Pubkey] = state
+	app.Memberships.Unlock()
+	return nil
+}
+
+// user is online but has not joined the channel, accrual is skipped
+func (app *solution) onUserNotInChannel(pubkey string, channels []channelConfig) {
+	if app.Config.ChannelReminder.Disabled || app.isAccrualFrozen(pubkey) {
+		return
+	}
+
+	state, isFound := app.getChannelMembership(pubkey)
+	isChanged := !isFound || state.IsMember
+	state.Pubkey = pubkey
+	state.IsMember = false
+
+	now := time.Now()
+	state.resetAfterCooldown(app.Config.ChannelReminder, now)
+	if !state.isReminderDue(app.Config.ChannelReminder, now) {
+		if isChanged {
+			if err := app.saveChannelMembership(state); err != nil {
+				logger.Error(err)
+			}
+		}
+		return
+	}
+
+	lang, _ := app.getUserLanguage(pubkey)
+	if err := app.sendMessageWithPriority(pubkey, tr(lang, "user.join_channel_notify"), messagePriorityLow); err != nil {
+		logger.Error(err)
+		return
+	}
+	// channel ID is sent separately, so the client shows it as a link
+	for _, ch := range channels {
+		if err := app.sendMessageWithPriority(pubkey, ch.ID, messagePriorityLow); err != nil {
+			logger.Error(err)
+		}
+	}
+
+	state.RemindersSent++
+	state.LastReminderAt = now.Unix()
+	if err := app.saveChannelMembership(state); err != nil {
+		logger.Error(err)
+	}
+	logger.Info("channel reminder " + strconv.Itoa(state.RemindersSent) + " sent to " + pubkey)
+}
+
+// user is in channel and gets points
+func (app *solution) onUserInChannel(pubkey string) {
+	state, isFound := app.getChannelMembership(pubkey)
+	if !isFound || state.IsMember {
+		return
+	}
+
+	// reminders count is kept, so the user who leaves again is not reminded right away
+	state.IsMember = true
+
+	now := time.Now()
+	if !app.Config.ChannelReminder.Disabled && state.isResumedNotifyDue(app.Config.ChannelReminder, now) {
+		lang, _ := app.getUserLanguage(pubkey)
+		if err := app.sendMessageWithPriority(pubkey, tr(lang, "user.accrual_resumed"), messagePriorityLow); err != nil {
+			logger.Error(err)
+		} else {
+			state.LastResumedAt = now.Unix()
+		}
+	}
+
+	if err := app.saveChannelMembership(state); err != nil {
+		logger.Error(err)
+	}
+}
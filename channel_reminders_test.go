@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChannelReminderDue(t *testing.T) {
+	cfg := channelReminderConfig{IntervalHours: 24, MaxCount: 2}
+	now := time.Now()
+
+	state := channelMembership{}
+	if !state.isReminderDue(cfg, now) {
+		t.Fatal("first reminder must be sent right away")
+	}
+
+	state.RemindersSent, state.LastReminderAt = 1, now.Add(-time.Hour).Unix()
+	if state.isReminderDue(cfg, now) {
+		t.Fatal("reminder must wait for the interval")
+	}
+	if !state.isReminderDue(cfg, now.Add(24*time.Hour)) {
+		t.Fatal("reminder must be sent after the interval")
+	}
+
+	state.RemindersSent = 2
+	if state.isReminderDue(cfg, now.Add(48*time.Hour)) {
+		t.Fatal("reminders count is limited")
+	}
+
+	if (channelMembership{IsMember: true}).isReminderDue(cfg, now) {
+		t.Fatal("members get no reminders")
+	}
+}
+
+func TestChannelReminderCooldown(t *testing.T) {
+	cfg := channelReminderConfig{IntervalHours: 24, MaxCount: 2, ResetDays: 30}
+	now := time.Now()
+
+	state := channelMembership{RemindersSent: 2, LastReminderAt: now.Unix()}
+	state.resetAfterCooldown(cfg, now.Add(24*time.Hour))
+	if state.RemindersSent != 2 {
+		t.Fatal("reminders count must be kept until the cooldown")
+	}
+
+	state.resetAfterCooldown(cfg, now.Add(30*24*time.Hour))
+	if state.RemindersSent != 0 {
+		t.Fatal("reminders count must be reset after the cooldown")
+	}
+	if !state.isReminderDue(cfg, now.Add(30*24*time.Hour)) {
+		t.Fatal("reminder must be sent after the cooldown")
+	}
+}
+
+func TestChannelResumedNotifyDue(t *testing.T) {
+	cfg := channelReminderConfig{IntervalHours: 24, MaxCount: 2}
+	now := time.Now()
+
+	if (channelMembership{}).isResumedNotifyDue(cfg, now) {
+		t.Fatal("user who got no reminders is not notified")
+	}
+
+	state := channelMembership{RemindersSent: 1, LastReminderAt: now.Unix()}
+	if !state.isResumedNotifyDue(cfg, now) {
+		t.Fatal("reminded user must be notified about resumed accrual")
+	}
+
+	state.LastResumedAt = now.Unix()
+	if state.isResumedNotifyDue(cfg, now.Add(time.Hour)) {
+		t.Fatal("user is notified once per reminder")
+	}
+
+	state.LastReminderAt = now.Add(time.Hour).Unix()
+	if state.isResumedNotifyDue(cfg, now.Add(2*time.Hour)) {
+		t.Fatal("resumed accrual notify must wait for the interval")
+	}
+	if !state.isResumedNotifyDue(cfg, now.Add(24*time.Hour)) {
+		t.Fatal("resumed accrual notify must be sent after the interval")
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	utopiago "github.com/Sagleft/utopialib-go"
+	"github.com/google/logger"
+)
+
+// channel presence of online contacts
+type channelOnline struct {
+	Config      channelConfig
+	Members     map[string]utopiago.ChannelContactData // nick -> data
+	UsersOnline int                                    // bot contacts in the channel, used by intervals
+}
+
+type channelsOnline []channelOnline
+
+// old config has one required channel with global rates
+func (app *solution) setupChannels() error {
+	if len(app.Config.Channels) == 0 {
+		if app.Config.ChannelID == "" {
+			return errors.New("channel is not set in `" + configJSONPath + "`")
+		}
+		app.Config.Channels = []channelConfig{{
+			ID:           app.Config.ChannelID,
+			Required:     true,
+			PointsPer24h: app.Config.PointsPer24h,
+			UseIntervals: app.Config.UseIntervals,
+			Intervals:    app.Config.Intervals,
+		}}
+	}
+
+	for _, ch := range app.Config.Channels {
+		if ch.ID == "" {
+			return errors.New("channel ID is not set in `channels`")
+		}
+	}
+	if app.Config.ChannelID == "" {
+		// giveaways are posted to the main channel
+		app.Config.ChannelID = app.Config.Channels[0].ID
+	}
+	return nil
+}
+
+func (ch channelConfig) getName() string {
+	if ch.Name != "" {
+		return ch.Name
+	}
+	return ch.ID
+}
+
+func (app *solution) getChannelsOnline() (channelsOnline, error) {
+	channels := channelsOnline{}
+	membersCount := 0
+	for _, ch := range app.Config.Channels {
+		contacts, err := app.Config.UtopiaCfg.GetChannelContacts(ch.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		channels = append(channels, channelOnline{
+			Config:  ch,
+			Members: app.getChannelOnlineMap(contacts),
+		})
+		membersCount += len(contacts)
+	}
+
+	if app.Config.HealthCheckStrictMode && membersCount == 0 {
+		return nil, doBotReboot()
+	}
+	return channels, nil
+}
+
+// counts bot contacts in every channel
+func (channels channelsOnline) countUsersOnline(contacts []utopiago.ContactData) {
+	for i := range channels {
+		channels[i].UsersOnline = 0
+		for _, contact := range contacts {
+			if _, isMember := channels[i].Members[contact.Nick]; isMember {
+				channels[i].UsersOnline++
+			}
+		}
+	}
+}
+
+// returns channels with the user and required channels without the user
+func (channels channelsOnline) getPresence(nick string) ([]channelOnline, []channelConfig) {
+	present := []channelOnline{}
+	missing := []channelConfig{}
+	for _, ch := range channels {
+		if _, isMember := ch.Members[nick]; isMember {
+			present = append(present, ch)
+		} else if ch.Config.Required {
+			missing = append(missing, ch.Config)
+		}
+	}
+	return present, missing
+}
+
+// true when the user is in all required channels and at least in one channel
+func (channels channelsOnline) isEligible(nick string) bool {
+	present, missing := channels.getPresence(nick)
+	return len(present) > 0 && len(missing) == 0
+}
+
+// returns unique channel members count
+func (channels channelsOnline) countMembers() int {
+	members := map[string]struct{}{}
+	for _, ch := range channels {
+		for nick := range ch.Members {
+			members[nick] = struct{}{}
+		}
+	}
+	return len(members)
+}
+
+// channels to remind about: missing required channels or all of them
+func (app *solution) getChannelsToJoin(missing []channelConfig) []channelConfig {
+	if len(missing) > 0 {
+		return missing
+	}
+	return app.Config.Channels
+}
+
+// sums points from all channels with the user
+func (app *solution) getChannelsPoints(present []channelOnline) float64 {
+	var points float64
+	now := time.Now()
+	for _, ch := range present {
+		points += app.getPointsPerTick(app.getChannelRate(ch.Config, ch.UsersOnline, now).getPointsPer24h())
+	}
+	return points
+}
+
+func (app *solution) tryEnterChannel() error {
+	for _, ch := range app.Config.Channels {
+		logger.Info("enter into utopia channel " + ch.getName() + "..")
+
+		if _, err := app.Config.UtopiaCfg.JoinChannel(ch.ID); err != nil {
+			app.onUtopiaError(err)
+		}
+	}
+	return nil
+}
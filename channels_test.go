@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	utopiago "github.com/Sagleft/utopialib-go"
+)
+
+func TestChannelsPresence(t *testing.T) {
+	channels := channelsOnline{
+		{
+			Config:  channelConfig{ID: "main", Required: true, PointsPer24h: 240},
+			Members: map[string]utopiago.ChannelContactData{"alice": {}, "bob": {}},
+		},
+		{
+			Config:  channelConfig{ID: "bonus", PointsPer24h: 24},
+			Members: map[string]utopiago.ChannelContactData{"alice": {}, "carol": {}},
+		},
+	}
+
+	if !channels.isEligible("alice") || !channels.isEligible("bob") {
+		t.Fatal("members of the required channel must be eligible")
+	}
+	if channels.isEligible("carol") {
+		t.Fatal("bonus channel alone must not give points")
+	}
+	if _, missing := channels.getPresence("dave"); len(missing) != 1 || missing[0].ID != "main" {
+		t.Fatalf("unexpected missing channels: %+v", missing)
+	}
+	if count := channels.countMembers(); count != 3 {
+		t.Fatalf("expected 3 members, got %d", count)
+	}
+
+	app := solution{Config: config{ContactsCronPerMinute: 60}}
+	present, _ := channels.getPresence("alice")
+	if points := app.getChannelsPoints(present); points != 11 {
+		t.Fatalf("expected 11 points per hour, got %v", points)
+	}
+}
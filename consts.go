@@ -13,23 +13,124 @@ const (
 	logsPath                       = "debug.log"
 	nicknameMaxLength              = 22
 	limitWithdrawNotifyTimeout     = time.Minute * 2
-	dialogFlowSessionID            = "123456789"
 
-	comandBalance  = "баланс"
-	comandBalance2 = "balance"
-	comandManager  = "менеджер"
+	comandClose  = "закрыть"
+	comandClose2 = "close"
+	comandTake   = "взять"
+	comandTake2  = "take"
 
 	testUserOnlinePubkey  = "07E7DDA00F179CDAD0A86881FA57D2E06962039BC2F04E2F5AB7B79D716ADA3C"
 	journalLogsTimeFormat = "2006-01-02"
 
-	gameVoucherTemplate        = "%s%s-%s-%s-%s"
-	gameVoucherActivateTimeout = time.Minute * 10
-	maxGameVoucherAmount       = 1000
+	defaultGameVoucherFormat     = "XXXX-XXXX-XXXX-XXX#"
+	voucherAlphabet              = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" // without look-alike symbols
+	legacyVoucherPattern         = "[A-Z0-9]{2}-[A-Z0-9]{4}-[A-Z0-9]{4}-[A-Z0-9]{4}"
+	minVoucherRandomSymbols      = 8
+	gameVoucherActivateTimeout   = time.Minute * 10 // first lockout, doubles with every next one
+	maxGameVoucherAmount         = 1000
+	maxVoucherBatchSize          = 1000
+	defaultGiveawayExpireMinutes = 60 * 24
+	voucherBatchesListLimit      = 20
+	voucherTimeFormat            = "2006-01-02 15:04"
+
+	voucherMaxFailedAttempts       = 5
+	voucherFailedAttemptsWindow    = time.Hour
+	voucherLockoutMax              = time.Hour * 24
+	voucherLockoutsResetTimeout    = time.Hour * 24
+	voucherBruteforceAlertLockouts = 2
+
+	defaultFraudFlagScore        = 5
+	fraudScoreAuthBurst          = 1
+	fraudScoreSimilarNick        = 1
+	fraudScoreStatusSync         = 2
+	fraudScoreWithdraw           = 2
+	fraudAuthBurstWindow         = time.Minute * 10
+	fraudAuthBurstCount          = 5
+	fraudAuthHistorySize         = 500
+	fraudSimilarNicksCount       = 3
+	fraudMinNickLength           = 4
+	fraudStatusSyncWindow        = time.Second * 5
+	fraudStatusSyncMinMatches    = 5
+	fraudStatusSyncMaxGroup      = 10
+	fraudMaxTrackedPairs         = 100000
+	fraudStatusSyncPairTTL       = time.Hour * 24 // synced logins of a pair are counted within it
+	fraudStatusSyncPruneInterval = time.Minute * 10
+	fraudScoreHalfLife           = time.Hour * 24 * 7
+	fraudWithdrawWindow          = time.Hour
+	fraudWithdrawSameAmountCount = 3
+	fraudReasonsMaxLength        = 2000
+	fraudListLimit               = 30
+
+	adminAPIDefaultLimit    = 50
+	adminAPIMaxLimit        = 500
+	adminAPIMaxRequestSize  = 1 << 20
+	defaultAdminAPIListen   = "127.0.0.1:8090"
+	defaultMonitoringListen = "127.0.0.1:9090"
+
+	healthContactsCheckMaxMissed = 3
+	healthStartupGracePeriod     = time.Minute * 2
+	healthCheckRequestTimeout    = time.Second * 3
+
+	defaultUtopiaUnit    = "startopia"
+	defaultBotUnit       = "bankbot"
+	defaultDockerSocket  = "/var/run/docker.sock"
+	dockerRequestTimeout = time.Minute
+
+	defaultReconnectMinBackoffSeconds    = 5
+	defaultReconnectMaxBackoffSeconds    = 300
+	defaultReconnectAttemptsBeforeReboot = 5
+	defaultReconnectMaxReboots           = 3
+	defaultReconnectRebootsWindowMinutes = 60
+
+	processedMessagesTTL = time.Hour * 24 * 7
+	resyncMessagesLimit  = 50 // last messages of a contact checked after reconnect
+
+	outboundQueuePollInterval     = time.Second * 5
+	outboundQueueBatchSize        = 50
+	outboundMaxAttempts           = 20 // about 6-11 hours of retries
+	outboundRetryMinDelay         = time.Second * 5
+	outboundRetryMaxDelay         = time.Hour
+	outboundErrorMaxLength        = 480
+	outboundDeadListLimit         = 10
+	outboundDeadTextPreviewLength = 100
+
+	defaultInboundMaxMessages        = 10
+	defaultInboundWindowSeconds      = 60
+	defaultInboundMuteMinutes        = 5
+	defaultInboundEscalateAfterMutes = 3
+	inboundEscalationWindow          = time.Hour * 24
+	inboundMaxMuteDuration           = time.Hour * 24
+	inboundCleanupInterval           = time.Minute * 10
+
+	defaultEventsPoolWorkers           = 8
+	defaultEventsPoolQueueSize         = 100
+	defaultEventsHandlerTimeoutSeconds = 30
+	defaultEventsReorderWindowMs       = 100
+
+	defaultShutdownTimeout = time.Second * 30
+	shutdownPollInterval   = time.Millisecond * 200
+
+	defaultLocalesDir = "locales"
+	defaultLanguage   = "ru"
+
+	defaultNLUMinScore  = 0.75
+	nluMinKeywordLength = 3 // shorter keywords are prefixes of too many words
+
+	faqQuestionMaxLength   = 500
+	faqTitleMaxLength      = 80
+	faqUnansweredListLimit = 30
+
+	supportTicketsListLimit = 30
+	supportHistoryLimit     = 50
+
+	userHistoryLimit = 10
+
+	defaultChannelReminderIntervalHours = 24
+	defaultChannelReminderMaxCount      = 3
+	defaultChannelReminderResetDays     = 30
 )
 
 var (
-	gameVoucherLength int
-
 	tips = []string{}
 
 	tgEmojiList = []string{
@@ -37,4 +138,6 @@ var (
 	}
 
 	autoRebootDisabled bool
+	restarter          restartStrategy = noopRestartStrategy{}
+	catalog                            = newI18nCatalog(defaultLanguage)
 )
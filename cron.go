@@ -1,9 +1,8 @@
 package main
 
 import (
+	"context"
 	"errors"
-	"fmt"
-	"os/exec"
 	"time"
 
 	tb "github.com/Sagleft/telegobot"
@@ -18,12 +17,13 @@ func (app *solution) setupCrons() error {
 	return checkErrors(
 		app.setupContactStatusesCron,
 		app.setupHealthckechCron,
+		app.setupVouchersGiveawayCron,
 	)
 }
 
 func (app *solution) setupContactStatusesCron() error {
 	app.HandleContactsCron = simplecron.NewCronHandler(
-		app.handleContacts, // callback
+		func() { app.handleContacts(app.Ctx) },                         // callback
 		time.Duration(app.getContactsCronTimeoutSeconds())*time.Second, // timeout
 	)
 	go app.HandleContactsCron.Run()
@@ -31,36 +31,42 @@ func (app *solution) setupContactStatusesCron() error {
 }
 
 func (app *solution) setupHealthckechCron() error {
-	cron := simplecron.NewCronHandler(
+	app.HealthCheckCron = simplecron.NewCronHandler(
 		app.doHealthCheck,  // callback
 		healthCheckTimeout, // timeout
 	)
-	go cron.Run()
+	go app.HealthCheckCron.Run()
 	return nil
 }
 
 func (app *solution) doHealthCheck() {
+	if app.Reconnect.getState() != reconnectStateConnected {
+		return // already reconnecting
+	}
+
 	// check connection
 	if !app.Config.UtopiaCfg.CheckClientConnection() {
-		err := app.utopiaConnect()
-		if err != nil {
-			logger.Error(err)
-			doUtopiaReboot()
-			return
-		}
+		app.Reconnect.request(reconnectRequest{Reason: tr(catalog.DefaultLang, "reconnect.api_unavailable")})
+		return
 	}
 
 	// check data
 	contactsData, err := app.getContactsData()
 	if err != nil {
 		logger.Error(err)
-		doUtopiaReboot()
+		app.Reconnect.request(reconnectRequest{
+			Reason:     tr(catalog.DefaultLang, "reconnect.contacts_error", err.Error()),
+			WithReboot: true,
+		})
 		return
 	}
 	if app.Config.HealthCheckStrictMode {
 		if contactsData.Contacts == 0 {
 			logger.Error("contacts not found")
-			doUtopiaReboot()
+			app.Reconnect.request(reconnectRequest{
+				Reason:     tr(catalog.DefaultLang, "reconnect.contacts_empty"),
+				WithReboot: true,
+			})
 			return
 		}
 	}
@@ -73,9 +79,9 @@ func doUtopiaReboot() error {
 	}
 
 	logger.Info("reboot utopia service..")
+	botMetrics.AutoReboots.addWithLabel("utopia", 1)
 
-	r := exec.Command("/usr/bin/systemctl", "restart", "startopia")
-	err := r.Run()
+	err := restarter.Restart(restartServiceUtopia)
 	if err != nil {
 		logger.Error(err)
 	}
@@ -89,52 +95,42 @@ func doBotReboot() error {
 	}
 
 	logger.Info("reboot bot service..")
+	botMetrics.AutoReboots.addWithLabel("bot", 1)
 
-	r := exec.Command("/usr/bin/systemctl", "restart", "bankbot")
-	err := r.Run()
+	err := restarter.Restart(restartServiceBot)
 	if err != nil {
 		logger.Error(err)
 	}
 	return err
 }
 
-func reconnect(connectionName string, connMethod func() error) error {
-	for {
-		for i := 1; i <= 5; i++ {
-			logger.Info("connect to Utopia Network..")
-			err := connMethod()
-			if err == nil {
-				logger.Info("connected")
-				return nil
-			}
-
-			logger.Warning("connection failed")
-			time.Sleep(time.Second * 12)
-		}
-
-		if err := doUtopiaReboot(); err != nil {
-			logger.Error(err)
-		}
-
-		logger.Warning("retry after 40s..")
-		time.Sleep(time.Second * 40)
-	}
-
-	//return errors.New("failed to connect to " + connectionName)
+// init Utopia connection & run reconnect supervisor
+func (app *solution) utopiaConnect() error {
+	app.Reconnect = newReconnectSupervisor(app.Config.Reconnect)
+	app.Reconnect.Connect = app.connectUtopia
+	app.Reconnect.Reboot = doUtopiaReboot
+	app.Reconnect.Notify = app.notifyModerators
+
+	app.Reconnect.reconnect(app.Ctx, reconnectRequest{Reason: tr(catalog.DefaultLang, "reconnect.startup")})
+	app.Reconnect.OnReconnected = app.resyncAfterReconnect
+	go app.Reconnect.run(app.Ctx)
+	return nil
 }
 
-func (app *solution) utopiaConnect() error {
-	err := reconnect("utopia", func() error {
-		if !app.Config.UtopiaCfg.CheckClientConnection() {
-			return errors.New("failed to connect to " + app.Config.UtopiaCfg.Host)
-		}
+func (app *solution) connectUtopia() error {
+	if !app.Config.UtopiaCfg.CheckClientConnection() {
+		return errors.New("failed to connect to " + app.Config.UtopiaCfg.Host)
+	}
 
-		return app.setupUtopiaWs()
-	})
-	if err != nil {
+	if err := app.setupUtopiaWs(); err != nil {
 		return err
 	}
 
+	if app.IsUtopiaConnected {
+		botMetrics.WsReconnects.inc()
+	}
+	app.IsUtopiaConnected = true
+
 	// setup logger
 	app.Config.UtopiaCfg.SetLogsCallback(app.onDebugLog)
 	return nil
@@ -142,6 +138,7 @@ func (app *solution) utopiaConnect() error {
 
 func (app *solution) onDebugLog(logMessage string) {
 	logger.Info(logMessage)
+	botMetrics.handleUtopiaLog(logMessage)
 }
 
 func (app *solution) setupUtopiaWs() error {
@@ -160,7 +157,7 @@ func (app *solution) setupUtopiaWs() error {
 	return app.Config.UtopiaCfg.WsSubscribe(utopiago.WsSubscribeTask{
 		OnConnected: app.handleWsConnected,
 		Callback:    app.handleWsEvent,
-		ErrCallback: onWsError,
+		ErrCallback: app.onWsError,
 	})
 }
 
@@ -168,19 +165,34 @@ func (app *solution) getContactsCronTimeoutSeconds() int {
 	return app.Config.ContactsCronPerMinute * 60
 }
 
-func (app *solution) lockContactsCheck() {
+// returns false when contacts check is already in progress
+func (app *solution) lockContactsCheck() bool {
+	app.ContactsCheckLock.Lock()
+	defer app.ContactsCheckLock.Unlock()
+
+	if app.IsContactsCheckInProgress {
+		return false
+	}
 	app.IsContactsCheckInProgress = true
+	return true
 }
 
 func (app *solution) unlockContactsCheck() {
+	app.ContactsCheckLock.Lock()
+	defer app.ContactsCheckLock.Unlock()
 	app.IsContactsCheckInProgress = false
 }
 
-func (app *solution) handleContacts() {
-	if app.IsContactsCheckInProgress {
+func (app *solution) isContactsCheckInProgress() bool {
+	app.ContactsCheckLock.Lock()
+	defer app.ContactsCheckLock.Unlock()
+	return app.IsContactsCheckInProgress
+}
+
+func (app *solution) handleContacts(ctx context.Context) {
+	if !app.lockContactsCheck() {
 		return
 	}
-	app.lockContactsCheck()
 	defer app.unlockContactsCheck()
 
 	contacts, err := app.Config.UtopiaCfg.GetContacts("")
@@ -195,59 +207,57 @@ func (app *solution) handleContacts() {
 		app.ContactsOnlineCache = contacts
 	}
 
-	channelOnline, err := app.getChannelOnline()
+	channels, err := app.getChannelsOnline()
 	if err != nil {
 		app.onUtopiaError(err)
 
 		// use cache when available
-		if len(app.ChannelOnlineCache) == 0 {
+		if len(app.ChannelsOnlineCache) == 0 {
 			return
 		}
-		channelOnline = app.ChannelOnlineCache
+		channels = app.ChannelsOnlineCache
 	} else {
-		app.ChannelOnlineCache = channelOnline
+		app.ChannelsOnlineCache = channels
 	}
 
-	channelOnlineMap := app.getChannelOnlineMap(channelOnline)
-	usersOnline, err := app.getUsersOnlineCount(contacts, channelOnlineMap)
-	if err != nil {
-		logger.Error(err)
-		return
-	}
+	channels.countUsersOnline(contacts)
+	app.buildContactsData(contacts, channels) // update metrics
+
+	app.startBudgetTick()
+	pointsAccruedBefore := botMetrics.PointsAccrued.get()
+	defer func() {
+		botMetrics.PointsAccruedTick.set(botMetrics.PointsAccrued.get() - pointsAccruedBefore)
+	}()
 
-	for pubkey := range app.UsersOnline {
-		err := app.handleContact(handleContactTask{
-			Pubkey:           pubkey,
-			WithPayment:      true,
-			ChannelOnlineMap: channelOnlineMap,
-			UsersOnlineCount: usersOnline,
+	for _, pubkey := range app.getUsersOnlinePubkeys() {
+		if ctx.Err() != nil {
+			logger.Warning("contacts check is canceled: " + ctx.Err().Error())
+			return
+		}
+
+		err := app.handleContact(ctx, handleContactTask{
+			Pubkey:      pubkey,
+			WithPayment: true,
+			Channels:    channels,
 		})
 		if err != nil {
 			app.onUtopiaError(err)
-			break
+			return
 		}
 	}
+	app.Health.onContactsChecked()
 }
 
 func (app *solution) onUtopiaError(err error) {
 	logger.Error(err)
 
 	logger.Info("check is connection broken..")
-	if utopiago.CheckErrorConnBroken(err) {
-		logger.Error("connection is broken. reboot..")
-
-		if err := doUtopiaReboot(); err != nil {
-			logger.Error(fmt.Errorf("failed to reboot Utopia: %w", err))
-			return
-		}
-
-		logger.Info("wait " + waitAfterUtopiaReboot.String() + " to reboot U..")
-		time.Sleep(waitAfterUtopiaReboot)
-
-		if err := app.utopiaConnect(); err != nil {
-			logger.Error(fmt.Errorf("failed to reconnect Utopia: %w", err))
-			return
-		}
+	if utopiago.CheckErrorConnBroken(err) && app.Reconnect != nil {
+		logger.Error("connection is broken. reconnect..")
+		app.Reconnect.request(reconnectRequest{
+			Reason:     tr(catalog.DefaultLang, "reconnect.conn_broken", err.Error()),
+			WithReboot: true,
+		})
 		return
 	}
 
@@ -260,8 +270,11 @@ func (app *solution) notifyModeratorsAboutError(err error) {
 		return
 	}
 
+	app.notifyModerators("🤖 Ошибка соединения или запроса: " + err.Error())
+}
+
+func (app *solution) notifyModerators(msg string) {
 	if app.Config.TelegramModeratorsChat != 0 {
-		msg := "🤖 Ошибка соединения или запроса: " + err.Error()
 		if _, tgErr := app.TelegramBot.Send(tb.ChatID(app.Config.TelegramModeratorsChat), msg); tgErr != nil {
 			logger.Error(tgErr)
 		}
@@ -272,29 +285,13 @@ func isUserOnline(contact utopiago.ContactData) bool {
 	return contact.IsOnline() || contact.IsAway() || contact.IsDoNotDisturb()
 }
 
-// с учетом онлайна в чате
-func (app *solution) getUsersOnlineCount(
-	contacts []utopiago.ContactData,
-	channelOnlineMap map[string]utopiago.ChannelContactData,
-) (int, error) {
-	var usersOnline int = 0
-	for _, contact := range contacts {
-		_, isUserOnlineInChannel := channelOnlineMap[contact.Pubkey]
-		if isUserOnlineInChannel {
-			usersOnline++
-		}
-	}
-	return usersOnline, nil
-}
-
 type handleContactTask struct {
-	Pubkey           string
-	WithPayment      bool
-	ChannelOnlineMap map[string]utopiago.ChannelContactData
-	UsersOnlineCount int // used with WithPayment param
+	Pubkey      string
+	WithPayment bool
+	Channels    channelsOnline // used with WithPayment param
 }
 
-func (app *solution) handleContact(task handleContactTask) error {
+func (app *solution) handleContact(ctx context.Context, task handleContactTask) error {
 	if app.isUserModerator(task.Pubkey) {
 		return nil // ignore on moderator contact
 	}
@@ -308,19 +305,41 @@ func (app *solution) handleContact(task handleContactTask) error {
 		return nil
 	}
 
-	_, isOnlineInChannel := task.ChannelOnlineMap[contact.Nick]
-	if !isOnlineInChannel {
-		return nil // user not online in channel
+	present, missing := task.Channels.getPresence(contact.Nick)
+	if len(present) == 0 || len(missing) > 0 {
+		if task.WithPayment {
+			app.onUserNotInChannel(task.Pubkey, app.getChannelsToJoin(missing))
+		}
+		return nil // user not online in channels
 	}
 
 	//if app.isUserInOnlineData(task.Pubkey) {
 	if task.WithPayment {
-		points := app.getPointsByPeriod(task.UsersOnlineCount)
+		// online time is counted for stats even when accrual is frozen
+		if err := app.DB.addOnlineMinutes(ctx, task.Pubkey, app.Config.ContactsCronPerMinute); err != nil {
+			logger.Error(err)
+		}
+
+		if app.isAccrualFrozen(task.Pubkey) {
+			return nil // accrual frozen by moderator or until fraud review
+		}
+
+		app.onUserInChannel(task.Pubkey)
+
+		points := app.limitAccrual(task.Pubkey, app.getChannelsPoints(present))
+		if points <= 0 {
+			return nil // daily budget or user cap is spent
+		}
 		//logger.Info("добавление " + formatFloat(points) + " пользователю " + task.Pubkey)
-		err := app.DB.addUserPoints(points, task.Pubkey)
+		err := app.DB.addUserPoints(ctx, points, task.Pubkey)
 		if err != nil {
 			return err
 		}
+		app.chargeAccrual(task.Pubkey, points)
+		if err := app.DB.addPointsHistory(ctx, task.Pubkey, pointsKindAccrual, points); err != nil {
+			logger.Error(err)
+		}
+		botMetrics.PointsAccrued.add(points)
 	}
 	return nil
 }
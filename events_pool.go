@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
+
+	utopiago "github.com/Sagleft/utopialib-go"
+	"github.com/google/logger"
+)
+
+// processes websocket events in parallel.
+// events of one user are handled by the same worker, so they are never handled concurrently
+// and their order is preserved
+type eventsPool struct {
+	sync.RWMutex
+	Ctx            context.Context // parent for handlers contexts
+	Workers        []chan utopiago.WsEvent
+	WorkersDone    sync.WaitGroup
+	IsStopped      bool
+	HandlerTimeout time.Duration
+	ReorderWindow  time.Duration
+	Handle         func(ctx context.Context, event utopiago.WsEvent)
+}
+
+// event waiting in the worker for the events sent before it
+type pendingWsEvent struct {
+	Event      utopiago.WsEvent
+	Key        string
+	ID         float64 // message id, 0 when the event has no id
+	ReceivedAt time.Time
+}
+
+// utopialib runs the callback in a new goroutine for every event,
+// so a message can be pushed before the previous one. the buffer holds events
+// for the window and returns messages of one user in the order of their ids
+type eventsReorderBuffer struct {
+	Window time.Duration
+	Events []pendingWsEvent // in the order of arrival
+}
+
+func newEventsPool(
+	ctx context.Context,
+	cfg eventsPoolConfig,
+	handle func(ctx context.Context, event utopiago.WsEvent),
+) *eventsPool {
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaultEventsPoolWorkers
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultEventsPoolQueueSize
+	}
+	if cfg.HandlerTimeoutSeconds <= 0 {
+		cfg.HandlerTimeoutSeconds = defaultEventsHandlerTimeoutSeconds
+	}
+	if cfg.ReorderWindowMs <= 0 {
+		cfg.ReorderWindowMs = defaultEventsReorderWindowMs
+	}
+
+	p := &eventsPool{
+		Ctx:            ctx,
+		Workers:        make([]chan utopiago.WsEvent, cfg.Workers),
+		HandlerTimeout: time.Duration(cfg.HandlerTimeoutSeconds) * time.Second,
+		ReorderWindow:  time.Duration(cfg.ReorderWindowMs) * time.Millisecond,
+		Handle:         handle,
+	}
+	for i := range p.Workers {
+		p.Workers[i] = make(chan utopiago.WsEvent, cfg.QueueSize)
+	}
+	return p
+}
+
+func (p *eventsPool) run() {
+	for _, events := range p.Workers {
+		p.WorkersDone.Add(1)
+		go p.runWorker(events)
+	}
+}
+
+func (p *eventsPool) runWorker(events chan utopiago.WsEvent) {
+	defer p.WorkersDone.Done()
+
+	buffer := eventsReorderBuffer{Window: p.ReorderWindow}
+	isStopped := false
+	for !isStopped || len(buffer.Events) > 0 {
+		var ready <-chan time.Time
+		if delay, isFound := buffer.getDelay(time.Now()); isFound {
+			ready = time.After(delay)
+		}
+
+		select {
+		case event, isOpen := <-events:
+			if !isOpen {
+				// pool is stopped, the rest of the events are not waited for
+				isStopped = true
+				events = nil
+				break
+			}
+			buffer.add(event, time.Now())
+		case <-ready:
+		}
+
+		for {
+			event, isFound := buffer.pop(time.Now(), isStopped)
+			if !isFound {
+				break
+			}
+			p.handleEvent(event)
+			botMetrics.WsEventsQueue.add(-1)
+		}
+	}
+}
+
+func (b *eventsReorderBuffer) add(event utopiago.WsEvent, now time.Time) {
+	id, err := event.GetFloat("id")
+	if err != nil {
+		id = 0
+	}
+	b.Events = append(b.Events, pendingWsEvent{
+		Event:      event,
+		Key:        getEventWorkerKey(event),
+		ID:         id,
+		ReceivedAt: now,
+	})
+}
+
+// returns the time left until the first event is ready
+func (b *eventsReorderBuffer) getDelay(now time.Time) (time.Duration, bool) {
+	if len(b.Events) == 0 {
+		return 0, false
+	}
+	return b.Events[0].ReceivedAt.Add(b.Window).Sub(now), true
+}
+
+// returns the next event when the first one has waited for the window.
+// force returns it without waiting
+func (b *eventsReorderBuffer) pop(now time.Time, force bool) (utopiago.WsEvent, bool) {
+	if len(b.Events) == 0 {
+		return utopiago.WsEvent{}, false
+	}
+	first := b.Events[0]
+	if !force && now.Sub(first.ReceivedAt) < b.Window {
+		return utopiago.WsEvent{}, false
+	}
+
+	// earlier message of the same user could arrive after the first event
+	next := 0
+	if first.ID > 0 {
+		for i, pending := range b.Events {
+			if pending.Key == first.Key && pending.ID > 0 && pending.ID < b.Events[next].ID {
+				next = i
+			}
+		}
+	}
+
+	event := b.Events[next].Event
+	b.Events = append(b.Events[:next], b.Events[next+1:]...)
+	return event, true
+}
+
+func (p *eventsPool) handleEvent(event utopiago.WsEvent) {
+	ctx, cancel := context.WithTimeout(p.Ctx, p.HandlerTimeout)
+	defer cancel()
+
+	p.Handle(ctx, event)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		botMetrics.WsHandlerTimeouts.inc()
+		logger.Warning(event.Type + " handler timed out after " + p.HandlerTimeout.String())
+	}
+}
+
+func getEventWorkerKey(event utopiago.WsEvent) string {
+	pubkey, err := event.GetString("pk")
+	if err != nil {
+		return event.Type
+	}
+	return pubkey
+}
+
+// blocks when the worker queue is full
+func (p *eventsPool) push(event utopiago.WsEvent) {
+	p.RLock()
+	defer p.RUnlock()
+	if p.IsStopped {
+		return
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(getEventWorkerKey(event)))
+	events := p.Workers[hash.Sum32()%uint32(len(p.Workers))]
+
+	if len(events) == cap(events) {
+		logger.Warning("events queue is full, wait for " + event.Type + " handling..")
+	}
+	botMetrics.WsEventsQueue.add(1)
+	events <- event
+}
+
+// stops accepting events & waits until queued events are handled
+func (p *eventsPool) stop(ctx context.Context) error {
+	p.Lock()
+	if !p.IsStopped {
+		p.IsStopped = true
+		for _, events := range p.Workers {
+			close(events)
+		}
+	}
+	p.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.WorkersDone.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errors.New("events queue is not empty: " + strconv.Itoa(p.getQueueDepth()))
+	}
+}
+
+// returns queued events count
+func (p *eventsPool) getQueueDepth() int {
+	depth := 0
+	for _, events := range p.Workers {
+		depth += len(events)
+	}
+	return depth
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	utopiago "github.com/Sagleft/utopialib-go"
+)
+
+func TestEventsPoolUserWorker(t *testing.T) {
+	var lock sync.Mutex
+	inProgress := map[string]bool{}
+	handled := map[string]int{}
+	wg := sync.WaitGroup{}
+
+	pool := newEventsPool(context.Background(), eventsPoolConfig{Workers: 4, QueueSize: 10}, func(ctx context.Context, event utopiago.WsEvent) {
+		defer wg.Done()
+		pubkey, _ := event.GetString("pk")
+
+		lock.Lock()
+		if inProgress[pubkey] {
+			t.Errorf("user %s: events are handled concurrently", pubkey)
+		}
+		inProgress[pubkey] = true
+		lock.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		lock.Lock()
+		defer lock.Unlock()
+		inProgress[pubkey] = false
+		handled[pubkey]++
+	})
+	pool.run()
+
+	users := []string{"A", "B", "C", "D", "E"}
+	for i := 0; i < 20; i++ {
+		for _, pubkey := range users {
+			wg.Add(1)
+			pool.push(utopiago.WsEvent{
+				Type: "newInstantMessage",
+				Data: map[string]interface{}{"pk": pubkey},
+			})
+		}
+	}
+	wg.Wait()
+
+	for _, pubkey := range users {
+		if handled[pubkey] != 20 {
+			t.Fatalf("user %s: expected 20 events, got %v", pubkey, handled[pubkey])
+		}
+	}
+}
+
+func TestEventsPoolUserOrder(t *testing.T) {
+	var lock sync.Mutex
+	handled := map[string][]float64{}
+	wg := sync.WaitGroup{}
+
+	pool := newEventsPool(context.Background(), eventsPoolConfig{Workers: 4, ReorderWindowMs: 50}, func(ctx context.Context, event utopiago.WsEvent) {
+		defer wg.Done()
+		pubkey, _ := event.GetString("pk")
+		id, _ := event.GetFloat("id")
+
+		lock.Lock()
+		defer lock.Unlock()
+		handled[pubkey] = append(handled[pubkey], id)
+	})
+	pool.run()
+
+	// events are pushed like utopialib does: every one in its own goroutine
+	users := []string{"A", "B", "C"}
+	for id := 1; id <= 20; id++ {
+		for _, pubkey := range users {
+			wg.Add(1)
+			go pool.push(utopiago.WsEvent{
+				Type: "newInstantMessage",
+				Data: map[string]interface{}{"pk": pubkey, "id": float64(id)},
+			})
+		}
+	}
+	wg.Wait()
+
+	for _, pubkey := range users {
+		if len(handled[pubkey]) != 20 {
+			t.Fatalf("user %s: expected 20 events, got %v", pubkey, len(handled[pubkey]))
+		}
+		for i, id := range handled[pubkey] {
+			if id != float64(i+1) {
+				t.Fatalf("user %s: events are handled out of order: %v", pubkey, handled[pubkey])
+			}
+		}
+	}
+}
+
+func TestEventsReorderBuffer(t *testing.T) {
+	now := time.Now()
+	buffer := eventsReorderBuffer{Window: time.Second}
+	for _, id := range []float64{3, 1, 2} {
+		buffer.add(utopiago.WsEvent{
+			Type: "newInstantMessage",
+			Data: map[string]interface{}{"pk": "A", "id": id},
+		}, now)
+	}
+	// event without id is handled in the order of arrival
+	buffer.add(utopiago.WsEvent{Type: "contactStatusNotification", Data: map[string]interface{}{"pk": "A"}}, now)
+
+	if _, isFound := buffer.pop(now, false); isFound {
+		t.Fatal("expected the event to wait for the window")
+	}
+
+	ids := []float64{}
+	for {
+		event, isFound := buffer.pop(now.Add(time.Second), false)
+		if !isFound {
+			break
+		}
+		id, _ := event.GetFloat("id")
+		ids = append(ids, id)
+	}
+	if len(ids) != 4 || ids[0] != 1 || ids[1] != 2 || ids[2] != 3 || ids[3] != 0 {
+		t.Fatalf("unexpected events order: %v", ids)
+	}
+}
+
+func TestEventsPoolHandlerTimeout(t *testing.T) {
+	pool := newEventsPool(context.Background(), eventsPoolConfig{Workers: 1, HandlerTimeoutSeconds: 1}, nil)
+	pool.HandlerTimeout = 10 * time.Millisecond
+
+	timeoutsBefore := botMetrics.WsHandlerTimeouts.get()
+	var ctxErr error
+	pool.Handle = func(ctx context.Context, event utopiago.WsEvent) {
+		<-ctx.Done()
+		ctxErr = ctx.Err()
+	}
+	pool.handleEvent(utopiago.WsEvent{Type: "test"})
+
+	if ctxErr != context.DeadlineExceeded {
+		t.Fatalf("expected handler context deadline, got %v", ctxErr)
+	}
+	if botMetrics.WsHandlerTimeouts.get() != timeoutsBefore+1 {
+		t.Fatal("expected handler timeout metric increment")
+	}
+}
@@ -47,14 +47,14 @@ func getLogsByUserFile(pubkey string) (string, error) {
 	return result, nil
 }
 
-func (app *solution) getLogsByUser(pubkey string, replyToTelegramUserID int64) error {
+func (app *solution) getLogsByUser(lang, pubkey string, replyToTelegramUserID int64) error {
 	fileData, err := getLogsByUserFile(pubkey)
 	if err != nil {
 		return err
 	}
 
 	if fileData == "" {
-		_, err = app.TelegramBot.Send(tb.ChatID(replyToTelegramUserID), "логи не найдены")
+		_, err = app.TelegramBot.Send(tb.ChatID(replyToTelegramUserID), tr(lang, "mod.logs_not_found"))
 		return err
 	}
 
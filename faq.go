@@ -0,0 +1,342 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/logger"
+)
+
+type faqEntry struct {
+	ID        int64
+	Questions []string
+	Keywords  []string
+	Answer    string
+	Lang      string // empty for all languages
+	Hits      int
+	UpdatedAt int64
+}
+
+type faqUnanswered struct {
+	Question string
+	Lang     string
+	Count    int
+	LastAt   int64
+}
+
+// FAQ entries matched against unknown user messages
+type faqBase struct {
+	sync.RWMutex
+	Entries  []faqEntry
+	MinScore float64
+}
+
+// returns nil if there is no suitable entry
+func (b *faqBase) find(lang, text string) *faqEntry {
+	b.RLock()
+	defer b.RUnlock()
+
+	words := splitNLUWords(text)
+	var best *faqEntry
+	bestScore := 0.0
+	for i, entry := range b.Entries {
+		if entry.Lang != "" && entry.Lang != lang {
+			continue
+		}
+
+		score := nluIntent{Keywords: entry.Keywords, Phrases: entry.Questions}.getScore(words)
+		if score >= b.MinScore && score > bestScore {
+			best, bestScore = &b.Entries[i], score
+		}
+	}
+	return best
+}
+
+func (app *solution) initFAQ() error {
+	logger.Info("load faq..")
+
+	app.FAQ.MinScore = app.Config.FAQMinScore
+	if app.FAQ.MinScore <= 0 {
+		app.FAQ.MinScore = defaultNLUMinScore
+	}
+	return app.reloadFAQ()
+}
+
+func (app *solution) reloadFAQ() error {
+	entries, err := app.DB.getFAQEntries()
+	if err != nil {
+		return err
+	}
+
+	app.FAQ.Lock()
+	defer app.FAQ.Unlock()
+	app.FAQ.Entries = entries
+	return nil
+}
+
+// returns empty string if there is no answer
+func (app *solution) findFAQAnswer(lang, text string) string {
+	entry := app.FAQ.find(lang, text)
+	if entry == nil {
+		return ""
+	}
+
+	if err := app.DB.increaseFAQEntryHits(entry.ID); err != nil {
+		logger.Error(err)
+	}
+	return entry.Answer
+}
+
+func getFAQQuestionHash(question string) string {
+	hash := sha256.Sum256([]byte(question))
+	return hex.EncodeToString(hash[:])
+}
+
+func (app *solution) saveUnansweredQuestion(pubkey, lang, text string) {
+	question := strings.Join(splitNLUWords(text), " ")
+	if question == "" {
+		return
+	}
+
+	err := app.DB.saveFAQUnanswered(
+		getFAQQuestionHash(question), LimitStringLength(question, faqQuestionMaxLength), lang, pubkey,
+	)
+	if err != nil {
+		logger.Error(err)
+	}
+}
+
+// faq entry fields from moderator message lines:
+//
+//	вопросы: как вывести баллы?; когда придут баллы
+//	ключи: вывод, withdraw
+//	язык: ru
+//	ответ: answer text, can be multiline
+func parseFAQEntryFields(lines []string) map[string]string {
+	labels := map[string]string{
+		"вопросы":   "questions",
+		"questions": "questions",
+		"ключи":     "keywords",
+		"keywords":  "keywords",
+		"язык":      "lang",
+		"lang":      "lang",
+		"ответ":     "answer",
+		"answer":    "answer",
+	}
+
+	fields := map[string]string{}
+	for i, line := range lines {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) < 2 {
+			continue
+		}
+		field, isFound := labels[strings.ToLower(strings.TrimSpace(parts[0]))]
+		if !isFound {
+			continue
+		}
+
+		if field == "answer" {
+			// the rest of message is the answer
+			fields[field] = strings.TrimSpace(strings.Join(append([]string{parts[1]}, lines[i+1:]...), "\n"))
+			break
+		}
+		fields[field] = strings.TrimSpace(parts[1])
+	}
+	return fields
+}
+
+func splitFAQList(raw, separator string) []string {
+	result := []string{}
+	for _, item := range strings.Split(raw, separator) {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// applies parsed fields to the entry
+func (e *faqEntry) update(fields map[string]string) {
+	if questions, isFound := fields["questions"]; isFound {
+		e.Questions = splitFAQList(questions, ";")
+	}
+	if keywords, isFound := fields["keywords"]; isFound {
+		e.Keywords = []string{}
+		for _, keyword := range splitFAQList(strings.ToLower(keywords), ",") {
+			if isNLUKeywordValid(keyword) {
+				e.Keywords = append(e.Keywords, keyword)
+			}
+		}
+	}
+	if lang, isFound := fields["lang"]; isFound {
+		e.Lang = strings.ToLower(lang)
+	}
+	if answer, isFound := fields["answer"]; isFound {
+		e.Answer = answer
+	}
+}
+
+func (e faqEntry) validate() error {
+	if len(e.Questions) == 0 && len(e.Keywords) == 0 {
+		return errors.New("questions or keywords are required")
+	}
+	if e.Answer == "" {
+		return errors.New("answer is required")
+	}
+	if e.Lang != "" && !catalog.has(e.Lang) {
+		return errors.New("unknown language: " + e.Lang)
+	}
+	return nil
+}
+
+// faq
+// faq <id>
+// faq добавить
+// faq изменить <id>
+// faq удалить <id>
+// faq неотвеченные
+// faq очистить
+func (app *solution) handleFAQRequest(lang, messageText string) ([]string, error) {
+	lines := strings.Split(messageText, "\n")
+	args := strings.Fields(strings.ToLower(lines[0]))[1:]
+	if len(args) == 0 {
+		return app.handleFAQList(lang)
+	}
+
+	getEntryID := func() (int64, error) {
+		if len(args) < 2 {
+			return 0, errors.New("faq entry number is not set")
+		}
+		return parseFAQEntryID(args[1])
+	}
+
+	switch args[0] {
+	default:
+		id, err := parseFAQEntryID(args[0])
+		if err != nil {
+			return []string{tr(lang, "mod.usage.faq")}, nil
+		}
+		return app.handleFAQView(lang, id)
+	case "добавить", "add":
+		return app.handleFAQSave(lang, 0, lines[1:])
+	case "изменить", "edit":
+		id, err := getEntryID()
+		if err != nil {
+			return nil, err
+		}
+		return app.handleFAQSave(lang, id, lines[1:])
+	case "удалить", "delete":
+		id, err := getEntryID()
+		if err != nil {
+			return nil, err
+		}
+		if err := app.DB.deleteFAQEntry(id); err != nil {
+			return nil, err
+		}
+		return []string{tr(lang, "mod.faq_deleted", id)}, app.reloadFAQ()
+	case "неотвеченные", "unanswered":
+		return app.handleFAQUnansweredList(lang)
+	case "очистить", "clear":
+		if err := app.DB.clearFAQUnanswered(); err != nil {
+			return nil, err
+		}
+		return []string{tr(lang, "mod.faq_unanswered_cleared")}, nil
+	}
+}
+
+func parseFAQEntryID(raw string) (int64, error) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(raw, "#"), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, errors.New("invalid faq entry number: " + raw)
+	}
+	return id, nil
+}
+
+func (app *solution) handleFAQList(lang string) ([]string, error) {
+	app.FAQ.RLock()
+	defer app.FAQ.RUnlock()
+
+	if len(app.FAQ.Entries) == 0 {
+		return []string{tr(lang, "mod.faq_empty")}, nil
+	}
+
+	msg := tr(lang, "mod.faq_title")
+	for _, e := range app.FAQ.Entries {
+		title := strings.Join(e.Questions, "; ")
+		if title == "" {
+			title = strings.Join(e.Keywords, ", ")
+		}
+		msg += tr(lang, "mod.faq_item", e.ID, LimitStringLength(title, faqTitleMaxLength), e.Hits)
+	}
+	return []string{msg}, nil
+}
+
+func (app *solution) handleFAQView(lang string, id int64) ([]string, error) {
+	entry, err := app.DB.getFAQEntry(id)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return []string{tr(lang, "mod.faq_not_found")}, nil
+	}
+
+	entryLang := entry.Lang
+	if entryLang == "" {
+		entryLang = "*"
+	}
+	return []string{tr(lang, "mod.faq_entry",
+		entry.ID, strings.Join(entry.Questions, "; "), strings.Join(entry.Keywords, ", "),
+		entryLang, entry.Hits, formatUnixTime(entry.UpdatedAt), entry.Answer,
+	)}, nil
+}
+
+// id 0 - new entry
+func (app *solution) handleFAQSave(lang string, id int64, lines []string) ([]string, error) {
+	fields := parseFAQEntryFields(lines)
+	if len(fields) == 0 {
+		return []string{tr(lang, "mod.usage.faq")}, nil
+	}
+
+	entry := &faqEntry{}
+	if id > 0 {
+		var err error
+		entry, err = app.DB.getFAQEntry(id)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			return []string{tr(lang, "mod.faq_not_found")}, nil
+		}
+	}
+
+	entry.update(fields)
+	if err := entry.validate(); err != nil {
+		return nil, err
+	}
+	if err := app.DB.saveFAQEntry(entry); err != nil {
+		return nil, err
+	}
+
+	msg := tr(lang, "mod.faq_saved", entry.ID)
+	return []string{msg}, app.reloadFAQ()
+}
+
+func (app *solution) handleFAQUnansweredList(lang string) ([]string, error) {
+	questions, err := app.DB.getFAQUnanswered(faqUnansweredListLimit)
+	if err != nil {
+		return nil, err
+	}
+	if len(questions) == 0 {
+		return []string{tr(lang, "mod.faq_unanswered_empty")}, nil
+	}
+
+	msg := tr(lang, "mod.faq_unanswered_title")
+	for _, q := range questions {
+		msg += tr(lang, "mod.faq_unanswered_item", q.Count, q.Lang, formatUnixTime(q.LastAt), q.Question)
+	}
+	return []string{msg}, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFAQEntryFields(t *testing.T) {
+	request := "faq добавить\n" +
+		"Вопросы: как вывести баллы?; когда придут баллы ;\n" +
+		"ключи: Вывод, на, withdraw\n" +
+		"ответ: от 150 баллов\nчерез менеджера"
+	lines := strings.Split(request, "\n")
+
+	entry := faqEntry{}
+	entry.update(parseFAQEntryFields(lines[1:]))
+	expected := faqEntry{
+		Questions: []string{"как вывести баллы?", "когда придут баллы"},
+		Keywords:  []string{"вывод", "withdraw"},
+		Answer:    "от 150 баллов\nчерез менеджера",
+	}
+	if !reflect.DeepEqual(entry, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, entry)
+	}
+	if err := entry.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// edit keeps fields that are not set
+	entry.update(parseFAQEntryFields([]string{"lang: EN"}))
+	if entry.Lang != "en" || entry.Answer != expected.Answer {
+		t.Fatalf("unexpected entry after edit: %+v", entry)
+	}
+
+	if err := (faqEntry{Keywords: []string{"вывод"}}).validate(); err == nil {
+		t.Fatal("expected error for entry without answer")
+	}
+}
+
+func TestFAQFind(t *testing.T) {
+	base := faqBase{
+		MinScore: defaultNLUMinScore,
+		Entries: []faqEntry{
+			{ID: 1, Questions: []string{"когда придут баллы"}, Answer: "в течение 30 минут"},
+			{ID: 2, Keywords: []string{"withdraw"}, Lang: "en", Answer: "from 150 points"},
+		},
+	}
+
+	if e := base.find("ru", "Когда придут баллы?"); e == nil || e.ID != 1 {
+		t.Fatalf("expected entry 1, got %+v", e)
+	}
+	if e := base.find("ru", "how to withdraw"); e != nil {
+		t.Fatalf("entry for other language must be skipped, got %+v", e)
+	}
+	if e := base.find("en", "how to withdraw"); e == nil || e.ID != 2 {
+		t.Fatalf("expected entry 2, got %+v", e)
+	}
+	if e := base.find("ru", "сколько стоит"); e != nil {
+		t.Fatalf("expected no entry, got %+v", e)
+	}
+}
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/google/logger"
+)
+
+const (
+	fraudStatusNone    = 0
+	fraudStatusFlagged = 1
+	fraudStatusCleared = 2
+)
+
+type fraudSignal struct {
+	Pubkey string
+	Score  float64
+	Reason string
+}
+
+type fraudEvent struct {
+	Pubkey string
+	Time   time.Time
+	Nick   string  // used with auth events
+	Amount float64 // used with withdraw events
+}
+
+// two accounts coming online at the same time
+type fraudSyncedPair struct {
+	Count  int // synced logins within fraudStatusSyncPairTTL
+	LastAt time.Time
+}
+
+// keeps recent events in memory & finds farming patterns
+type fraudDetector struct {
+	sync.Mutex
+	Auths         []fraudEvent
+	StatusChanges []fraudEvent // users came online
+	Withdrawals   []fraudEvent
+	SyncedPairs   map[string]fraudSyncedPair // "pubkey1:pubkey2" -> synced logins
+	PairsPrunedAt time.Time
+	Flagged       map[string]struct{}
+}
+
+// fraud data saved in db
+type fraudScore struct {
+	Pubkey    string
+	Score     float64
+	Status    int
+	Reasons   string
+	UpdatedAt int64 // unix timestamp
+}
+
+func newFraudDetector() *fraudDetector {
+	return &fraudDetector{
+		SyncedPairs: map[string]fraudSyncedPair{},
+		Flagged:     map[string]struct{}{},
+	}
+}
+
+// old signals weigh less: the score is halved every fraudScoreHalfLife
+func getDecayedFraudScore(score float64, updatedAt int64, now time.Time) float64 {
+	elapsed := now.Sub(time.Unix(updatedAt, 0))
+	if score <= 0 || elapsed <= 0 {
+		return score
+	}
+	return score * math.Pow(0.5, elapsed.Hours()/fraudScoreHalfLife.Hours())
+}
+
+func pruneFraudEvents(events []fraudEvent, now time.Time, window time.Duration) []fraudEvent {
+	for len(events) > 0 && now.Sub(events[0].Time) > window {
+		events = events[1:]
+	}
+	return events
+}
+
+// signals all matched users once the threshold is reached, then only the new ones
+func getBurstSignals(pubkey string, matches []string, threshold int, score float64, reason string) []fraudSignal {
+	if len(matches)+1 < threshold {
+		return nil
+	}
+
+	signals := []fraudSignal{{Pubkey: pubkey, Score: score, Reason: reason}}
+	if len(matches)+1 == threshold {
+		for _, match := range matches {
+			signals = append(signals, fraudSignal{Pubkey: match, Score: score, Reason: reason})
+		}
+	}
+	return signals
+}
+
+func normalizeNickForFraud(nick string) string {
+	result := ""
+	for _, r := range strings.ToLower(nick) {
+		if unicode.IsLetter(r) {
+			result += string(r)
+		}
+	}
+	return result
+}
+
+func getLevenshteinDistance(a, b string) int {
+	s1, s2 := []rune(a), []rune(b)
+	prev := make([]int, len(s2)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(s1); i++ {
+		cur := make([]int, len(s2)+1)
+		cur[0] = i
+		for j := 1; j <= len(s2); j++ {
+			cost := 1
+			if s1[i-1] == s2[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(minInt(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(s2)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func isNicknamesSimilar(nick1, nick2 string) bool {
+	a, b := normalizeNickForFraud(nick1), normalizeNickForFraud(nick2)
+	if len([]rune(a)) < fraudMinNickLength || len([]rune(b)) < fraudMinNickLength {
+		return false
+	}
+	return a == b || getLevenshteinDistance(a, b) <= 1
+}
+
+func (d *fraudDetector) onAuth(pubkey, nick string, now time.Time) []fraudSignal {
+	d.Lock()
+	defer d.Unlock()
+
+	var signals []fraudSignal
+
+	// accounts authorized in burst
+	burst := []string{}
+	for _, e := range pruneFraudEvents(d.Auths, now, fraudAuthBurstWindow) {
+		if e.Pubkey != pubkey {
+			burst = append(burst, e.Pubkey)
+		}
+	}
+	signals = append(signals, getBurstSignals(
+		pubkey, burst, fraudAuthBurstCount, fraudScoreAuthBurst, tr(catalog.DefaultLang, "fraud.reason_auth_burst"),
+	)...)
+
+	// similar nicknames
+	similar := []string{}
+	for _, e := range d.Auths {
+		if e.Pubkey != pubkey && isNicknamesSimilar(e.Nick, nick) {
+			similar = append(similar, e.Pubkey)
+		}
+	}
+	signals = append(signals, getBurstSignals(
+		pubkey, similar, fraudSimilarNicksCount, fraudScoreSimilarNick,
+		tr(catalog.DefaultLang, "fraud.reason_similar_nick", nick),
+	)...)
+
+	d.Auths = append(d.Auths, fraudEvent{Pubkey: pubkey, Nick: nick, Time: now})
+	if len(d.Auths) > fraudAuthHistorySize {
+		d.Auths = d.Auths[len(d.Auths)-fraudAuthHistorySize:]
+	}
+	return signals
+}
+
+func getFraudPairKey(pubkey1, pubkey2 string) string {
+	if pubkey1 > pubkey2 {
+		pubkey1, pubkey2 = pubkey2, pubkey1
+	}
+	return pubkey1 + ":" + pubkey2
+}
+
+// removes pairs not synced within the TTL
+func (d *fraudDetector) pruneSyncedPairs(now time.Time) {
+	if now.Sub(d.PairsPrunedAt) < fraudStatusSyncPruneInterval && len(d.SyncedPairs) <= fraudMaxTrackedPairs {
+		return
+	}
+	d.PairsPrunedAt = now
+
+	for pairKey, pair := range d.SyncedPairs {
+		if now.Sub(pair.LastAt) > fraudStatusSyncPairTTL {
+			delete(d.SyncedPairs, pairKey)
+		}
+	}
+	if len(d.SyncedPairs) > fraudMaxTrackedPairs {
+		d.SyncedPairs = map[string]fraudSyncedPair{}
+	}
+}
+
+// called when the user goes from offline to online
+func (d *fraudDetector) onUserOnline(pubkey string, now time.Time) []fraudSignal {
+	d.Lock()
+	defer d.Unlock()
+
+	d.StatusChanges = pruneFraudEvents(d.StatusChanges, now, fraudStatusSyncWindow)
+	d.StatusChanges = append(d.StatusChanges, fraudEvent{Pubkey: pubkey, Time: now})
+	if len(d.StatusChanges) > fraudStatusSyncMaxGroup {
+		// mass status change, most likely network issues
+		return nil
+	}
+
+	d.pruneSyncedPairs(now)
+
+	var signals []fraudSignal
+	for _, e := range d.StatusChanges {
+		if e.Pubkey == pubkey {
+			continue
+		}
+
+		pairKey := getFraudPairKey(pubkey, e.Pubkey)
+		pair := d.SyncedPairs[pairKey]
+		if now.Sub(pair.LastAt) > fraudStatusSyncPairTTL {
+			pair.Count = 0
+		}
+		pair.Count++
+		pair.LastAt = now
+		d.SyncedPairs[pairKey] = pair
+		if pair.Count%fraudStatusSyncMinMatches != 0 {
+			continue
+		}
+
+		signals = append(signals,
+			fraudSignal{
+				Pubkey: pubkey,
+				Score:  fraudScoreStatusSync,
+				Reason: tr(catalog.DefaultLang, "fraud.reason_status_sync", e.Pubkey),
+			},
+			fraudSignal{
+				Pubkey: e.Pubkey,
+				Score:  fraudScoreStatusSync,
+				Reason: tr(catalog.DefaultLang, "fraud.reason_status_sync", pubkey),
+			},
+		)
+	}
+	return signals
+}
+
+func (d *fraudDetector) onWithdraw(pubkey string, amount float64, now time.Time) []fraudSignal {
+	d.Lock()
+	defer d.Unlock()
+
+	d.Withdrawals = pruneFraudEvents(d.Withdrawals, now, fraudWithdrawWindow)
+
+	sameAmount := []string{}
+	for _, e := range d.Withdrawals {
+		if e.Pubkey != pubkey && math.Abs(e.Amount-amount) < 0.01 {
+			sameAmount = append(sameAmount, e.Pubkey)
+		}
+	}
+	d.Withdrawals = append(d.Withdrawals, fraudEvent{Pubkey: pubkey, Amount: amount, Time: now})
+
+	return getBurstSignals(
+		pubkey, sameAmount, fraudWithdrawSameAmountCount, fraudScoreWithdraw,
+		tr(catalog.DefaultLang, "fraud.reason_same_withdrawals", formatFloat(amount)),
+	)
+}
+
+func (d *fraudDetector) isFlagged(pubkey string) bool {
+	d.Lock()
+	defer d.Unlock()
+
+	_, isFlagged := d.Flagged[pubkey]
+	return isFlagged
+}
+
+func (d *fraudDetector) setFlagged(pubkey string, isFlagged bool) {
+	d.Lock()
+	defer d.Unlock()
+
+	if isFlagged {
+		d.Flagged[pubkey] = struct{}{}
+	} else {
+		delete(d.Flagged, pubkey)
+	}
+}
+
+func (app *solution) initFraudDetector() error {
+	app.Fraud = newFraudDetector()
+	if !app.Config.AntiFraud.Enabled {
+		return nil
+	}
+
+	logger.Info("load flagged accounts..")
+	pubkeys, err := app.DB.getFlaggedPubkeys()
+	if err != nil {
+		return err
+	}
+	for _, pubkey := range pubkeys {
+		app.Fraud.setFlagged(pubkey, true)
+	}
+	return nil
+}
+
+func (app *solution) getFraudFlagScore() float64 {
+	if app.Config.AntiFraud.FlagScore > 0 {
+		return app.Config.AntiFraud.FlagScore
+	}
+	return defaultFraudFlagScore
+}
+
+func (app *solution) handleFraudSignals(signals []fraudSignal) {
+	if !app.Config.AntiFraud.Enabled {
+		return
+	}
+
+	for _, signal := range signals {
+		if app.isUserModerator(signal.Pubkey) {
+			continue
+		}
+
+		score, err := app.DB.addFraudScore(signal)
+		if err != nil {
+			logger.Error(err)
+			continue
+		}
+
+		if score.Status == fraudStatusFlagged || score.Score < app.getFraudFlagScore() {
+			continue
+		}
+
+		if err := app.DB.setFraudStatus(signal.Pubkey, fraudStatusFlagged); err != nil {
+			logger.Error(err)
+			continue
+		}
+		app.Fraud.setFlagged(signal.Pubkey, true)
+
+		logger.Warning("user " + signal.Pubkey + " flagged as suspicious")
+		app.notifyModerators(tr(catalog.DefaultLang, "fraud.flagged",
+			signal.Pubkey, formatFloat(score.Score), score.Reasons, signal.Pubkey,
+		))
+	}
+}
+
+func (app *solution) isUserFraudFlagged(pubkey string) bool {
+	return app.Config.AntiFraud.Enabled && app.Fraud.isFlagged(pubkey)
+}
+
+func (app *solution) handleFlaggedUsersList(lang string) ([]string, error) {
+	scores, err := app.DB.getFraudScores(fraudStatusFlagged, fraudListLimit)
+	if err != nil {
+		return nil, err
+	}
+	if len(scores) == 0 {
+		return []string{tr(lang, "mod.flagged_empty")}, nil
+	}
+
+	msg := tr(lang, "mod.flagged_title")
+	for _, s := range scores {
+		score := getDecayedFraudScore(s.Score, s.UpdatedAt, time.Now())
+		msg += "\n" + s.Pubkey + ": " + formatFloat(score) + " (" + formatUnixTime(s.UpdatedAt) + ")"
+	}
+	return []string{msg}, nil
+}
+
+func (app *solution) handleFraudScoreView(lang, pubkey string) ([]string, error) {
+	score, err := app.DB.getFraudScore(pubkey)
+	if err != nil {
+		return nil, err
+	}
+	if score == nil {
+		return []string{tr(lang, "mod.fraud_score_empty")}, nil
+	}
+
+	return []string{tr(lang, "mod.fraud_score",
+		score.Pubkey, formatFloat(getDecayedFraudScore(score.Score, score.UpdatedAt, time.Now())), getFraudStatusName(lang, score.Status),
+		formatUnixTime(score.UpdatedAt), score.Reasons,
+	)}, nil
+}
+
+func getFraudStatusName(lang string, status int) string {
+	switch status {
+	default:
+		return strconv.Itoa(status)
+	case fraudStatusNone:
+		return tr(lang, "fraud.status_none")
+	case fraudStatusFlagged:
+		return tr(lang, "fraud.status_flagged")
+	case fraudStatusCleared:
+		return tr(lang, "fraud.status_cleared")
+	}
+}
+
+func (app *solution) handleFraudClear(lang, pubkey string) ([]string, error) {
+	if err := app.DB.clearFraudScore(pubkey); err != nil {
+		return nil, err
+	}
+	app.Fraud.setFlagged(pubkey, false)
+	return []string{tr(lang, "mod.fraud_cleared")}, nil
+}
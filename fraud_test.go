@@ -0,0 +1,87 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestFraudAuthBurst(t *testing.T) {
+	d := newFraudDetector()
+	now := time.Now()
+
+	var signals []fraudSignal
+	for i := 0; i < fraudAuthBurstCount; i++ {
+		signals = d.onAuth("pubkey"+strconv.Itoa(i), "user"+strconv.Itoa(i), now)
+	}
+
+	// every account in burst should be signaled once threshold is reached
+	burstSignals := 0
+	for _, s := range signals {
+		if s.Reason == tr(catalog.DefaultLang, "fraud.reason_auth_burst") {
+			burstSignals++
+		}
+	}
+	if burstSignals != fraudAuthBurstCount {
+		t.Fatalf("expected %v burst signals, got %v", fraudAuthBurstCount, burstSignals)
+	}
+}
+
+func TestFraudStatusSync(t *testing.T) {
+	d := newFraudDetector()
+	now := time.Now()
+
+	var signals []fraudSignal
+	for i := 0; i < fraudStatusSyncMinMatches; i++ {
+		now = now.Add(time.Minute)
+		d.onUserOnline("farm1", now)
+		signals = d.onUserOnline("farm2", now.Add(time.Second))
+	}
+	if len(signals) != 2 {
+		t.Fatalf("both accounts should be signaled, got %+v", signals)
+	}
+}
+
+func TestFraudSyncedPairsWindow(t *testing.T) {
+	d := newFraudDetector()
+	now := time.Now()
+
+	for i := 0; i < fraudStatusSyncMinMatches-1; i++ {
+		now = now.Add(time.Minute)
+		d.onUserOnline("user1", now)
+		d.onUserOnline("user2", now.Add(time.Second))
+	}
+
+	// old synced logins are not counted
+	now = now.Add(fraudStatusSyncPairTTL + time.Hour)
+	d.onUserOnline("user1", now)
+	if signals := d.onUserOnline("user2", now.Add(time.Second)); len(signals) != 0 {
+		t.Fatalf("expected no signals after the window, got %+v", signals)
+	}
+
+	d.onUserOnline("user3", now.Add(fraudStatusSyncPairTTL+fraudStatusSyncPruneInterval))
+	if _, isFound := d.SyncedPairs[getFraudPairKey("user1", "user2")]; isFound {
+		t.Fatal("expected the old pair to be pruned")
+	}
+}
+
+func TestDecayedFraudScore(t *testing.T) {
+	now := time.Unix(time.Now().Unix(), 0)
+	if score := getDecayedFraudScore(4, now.Unix(), now); score != 4 {
+		t.Fatalf("fresh score must not decay, got %v", score)
+	}
+
+	score := getDecayedFraudScore(4, now.Add(-fraudScoreHalfLife).Unix(), now)
+	if score < 1.99 || score > 2.01 {
+		t.Fatalf("score must be halved after the half-life, got %v", score)
+	}
+}
+
+func TestNicknamesSimilarity(t *testing.T) {
+	if !isNicknamesSimilar("Farmer01", "farmer_02") {
+		t.Fatal("nicknames should be similar")
+	}
+	if isNicknamesSimilar("Alice", "Robert") {
+		t.Fatal("nicknames should not be similar")
+	}
+}
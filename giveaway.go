@@ -0,0 +1,187 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	tb "github.com/Sagleft/telegobot"
+	"github.com/google/logger"
+	simplecron "github.com/sagleft/simple-cron"
+)
+
+type vouchersGiveaway struct {
+	sync.Mutex
+	Active map[string]giveawayVoucher // voucher code -> data
+	Rand   *rand.Rand                 // seeded once, used by giveaway cron only
+}
+
+type giveawayVoucher struct {
+	Code      string
+	Amount    float64
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+func (cfg giveawayConfig) validate() error {
+	if cfg.IntervalMinutes <= 0 {
+		return errors.New("vouchers giveaway interval is not set")
+	}
+	if cfg.MinAmount <= 0 || cfg.MaxAmount < cfg.MinAmount {
+		return errors.New("invalid vouchers giveaway amount range")
+	}
+	if cfg.MaxAmount > maxGameVoucherAmount {
+		return fmt.Errorf("max giveaway voucher amount is %v", maxGameVoucherAmount)
+	}
+	return nil
+}
+
+// returns random whole amount from the configured range
+func (cfg giveawayConfig) getRandomAmount(r *rand.Rand) float64 {
+	return math.Round(cfg.MinAmount + r.Float64()*(cfg.MaxAmount-cfg.MinAmount))
+}
+
+func (cfg giveawayConfig) getInterval() time.Duration {
+	return time.Duration(cfg.IntervalMinutes) * time.Minute
+}
+
+// removes & returns unclaimed vouchers
+func (g *vouchersGiveaway) popExpired(now time.Time) []giveawayVoucher {
+	g.Lock()
+	defer g.Unlock()
+
+	expired := []giveawayVoucher{}
+	for code, voucher := range g.Active {
+		if !now.Before(voucher.ExpiresAt) {
+			expired = append(expired, voucher)
+			delete(g.Active, code)
+		}
+	}
+	return expired
+}
+
+// unclaimed vouchers are deleted, so old posts in the channel can't be used
+func (app *solution) expireGiveawayVouchers() {
+	for _, voucher := range app.Giveaways.popExpired(time.Now()) {
+		if err := app.DB.deleteGiveawayVoucher(voucher.Code, true); err != nil {
+			logger.Error(err)
+			continue
+		}
+		logger.Info("giveaway voucher expired: " + voucher.Code)
+	}
+}
+
+// giveaways survive restarts
+func (app *solution) loadGiveawayVouchers() error {
+	vouchers, err := app.DB.getGiveawayVouchers()
+	if err != nil {
+		return err
+	}
+
+	app.Giveaways.Lock()
+	for _, voucher := range vouchers {
+		app.Giveaways.Active[voucher.Code] = voucher
+	}
+	app.Giveaways.Unlock()
+
+	app.expireGiveawayVouchers()
+	return nil
+}
+
+func (app *solution) setupVouchersGiveawayCron() error {
+	if !app.Config.VouchersGiveaway.Enabled {
+		return nil
+	}
+
+	logger.Info("setup vouchers giveaway..")
+	if err := app.Config.VouchersGiveaway.validate(); err != nil {
+		return err
+	}
+	if app.Config.VouchersGiveaway.ExpireMinutes <= 0 {
+		app.Config.VouchersGiveaway.ExpireMinutes = defaultGiveawayExpireMinutes
+	}
+	if err := app.loadGiveawayVouchers(); err != nil {
+		return err
+	}
+
+	app.VouchersGiveawayCron = simplecron.NewCronHandler(
+		app.runVouchersGiveaway,                   // callback
+		app.Config.VouchersGiveaway.getInterval(), // timeout
+	)
+	go app.VouchersGiveawayCron.Run()
+	return nil
+}
+
+func (app *solution) runVouchersGiveaway() {
+	app.expireGiveawayVouchers()
+
+	code, err := app.genGameVoucher()
+	if err != nil {
+		logger.Error(fmt.Errorf("failed to generate giveaway voucher: %w", err))
+		return
+	}
+
+	now := time.Now()
+	voucher := giveawayVoucher{
+		Code:      code,
+		Amount:    app.Config.VouchersGiveaway.getRandomAmount(app.Giveaways.Rand),
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Duration(app.Config.VouchersGiveaway.ExpireMinutes) * time.Minute),
+	}
+
+	if err := app.DB.saveGiveawayVoucher(voucher); err != nil {
+		logger.Error(fmt.Errorf("failed to save giveaway voucher: %w", err))
+		return
+	}
+
+	app.Giveaways.Lock()
+	app.Giveaways.Active[voucher.Code] = voucher
+	app.Giveaways.Unlock()
+
+	logger.Info("giveaway voucher created: " + voucher.Code)
+	app.broadcastGiveawayMessage(tr(catalog.DefaultLang, "giveaway.created", formatFloat(voucher.Amount), voucher.Code))
+}
+
+// checks if the activated voucher was given away and reports the winner
+func (app *solution) onGiveawayVoucherActivated(voucherCode, nickname string) {
+	app.Giveaways.Lock()
+	voucher, isExists := app.Giveaways.Active[voucherCode]
+	if isExists {
+		delete(app.Giveaways.Active, voucherCode)
+	}
+	app.Giveaways.Unlock()
+
+	if !isExists {
+		return
+	}
+	if err := app.DB.deleteGiveawayVoucher(voucherCode, false); err != nil {
+		logger.Error(err)
+	}
+
+	if nickname == "" {
+		nickname = "Anonymous"
+	}
+
+	elapsed := time.Since(voucher.CreatedAt).Round(time.Second)
+	app.broadcastGiveawayMessage(tr(catalog.DefaultLang, "giveaway.activated",
+		voucher.Code, nickname, elapsed.String(), formatFloat(voucher.Amount),
+	))
+}
+
+// sends message to the utopia channel & telegram notify chat
+func (app *solution) broadcastGiveawayMessage(msg string) {
+	if app.Config.ChannelID != "" {
+		if _, err := app.Config.UtopiaCfg.SendChannelMessage(app.Config.ChannelID, msg); err != nil {
+			app.onUtopiaError(fmt.Errorf("failed to send giveaway message to channel: %w", err))
+		}
+	}
+
+	if app.Config.TelegramNotifyChatID != 0 {
+		if _, err := app.TelegramBot.Send(tb.ChatID(app.Config.TelegramNotifyChatID), msg); err != nil {
+			logger.Error(err)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestGiveawayConfigValidate(t *testing.T) {
+	valid := giveawayConfig{Enabled: true, IntervalMinutes: 60, MinAmount: 5, MaxAmount: 20}
+	if err := valid.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if interval := valid.getInterval(); interval != time.Hour {
+		t.Fatalf("expected 1h interval, got %v", interval)
+	}
+
+	invalid := []giveawayConfig{
+		{IntervalMinutes: 0, MinAmount: 5, MaxAmount: 20},
+		{IntervalMinutes: 60, MinAmount: 0, MaxAmount: 20},
+		{IntervalMinutes: 60, MinAmount: 20, MaxAmount: 5},
+		{IntervalMinutes: 60, MinAmount: 5, MaxAmount: maxGameVoucherAmount + 1},
+	}
+	for _, cfg := range invalid {
+		if err := cfg.validate(); err == nil {
+			t.Fatalf("expected error for %+v", cfg)
+		}
+	}
+}
+
+func TestGiveawayDisabled(t *testing.T) {
+	app := solution{}
+	app.Config.VouchersGiveaway = giveawayConfig{IntervalMinutes: 0}
+	if err := app.setupVouchersGiveawayCron(); err != nil {
+		t.Fatal(err)
+	}
+	if app.VouchersGiveawayCron != nil {
+		t.Fatal("disabled giveaway must not be scheduled")
+	}
+}
+
+func TestGiveawayRandomAmount(t *testing.T) {
+	cfg := giveawayConfig{MinAmount: 5, MaxAmount: 20}
+	r := rand.New(rand.NewSource(1))
+
+	amounts := map[float64]struct{}{}
+	for i := 0; i < 1000; i++ {
+		amount := cfg.getRandomAmount(r)
+		if amount < cfg.MinAmount || amount > cfg.MaxAmount {
+			t.Fatalf("amount %v out of range", amount)
+		}
+		if amount != math.Round(amount) {
+			t.Fatalf("expected whole amount, got %v", amount)
+		}
+		amounts[amount] = struct{}{}
+	}
+	if len(amounts) < 10 {
+		t.Fatalf("expected different amounts, got %v", amounts)
+	}
+
+	fixed := giveawayConfig{MinAmount: 7, MaxAmount: 7}
+	if amount := fixed.getRandomAmount(r); amount != 7 {
+		t.Fatalf("expected 7, got %v", amount)
+	}
+}
+
+func TestGiveawayPopExpired(t *testing.T) {
+	now := time.Now()
+	g := vouchersGiveaway{Active: map[string]giveawayVoucher{
+		"T2E-OLD":  {Code: "T2E-OLD", ExpiresAt: now.Add(-time.Minute)},
+		"T2E-NOW":  {Code: "T2E-NOW", ExpiresAt: now},
+		"T2E-LIVE": {Code: "T2E-LIVE", ExpiresAt: now.Add(time.Hour)},
+	}}
+
+	expired := g.popExpired(now)
+	if len(expired) != 2 {
+		t.Fatalf("expected 2 expired vouchers, got %v", expired)
+	}
+	if _, isActive := g.Active["T2E-LIVE"]; !isActive || len(g.Active) != 1 {
+		t.Fatalf("expected only live voucher to stay, got %v", g.Active)
+	}
+	if len(g.popExpired(now)) != 0 {
+		t.Fatal("expired vouchers must be removed")
+	}
+}
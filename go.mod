@@ -4,10 +4,10 @@ go 1.17
 
 require (
 	cloud.google.com/go/dialogflow v1.12.0
-	github.com/Sagleft/swiss-knife v1.2.1
 	github.com/Sagleft/telegobot v1.0.2
 	github.com/Sagleft/utopialib-go v1.12.2
 	github.com/beefsack/go-rate v0.0.0-20220214233405-116f4ca011a0
+	github.com/common-nighthawk/go-figure v0.0.0-20210622060536-734e95fb86be
 	github.com/fatih/color v1.13.0
 	github.com/go-sql-driver/mysql v1.6.0
 	github.com/google/logger v1.1.1
@@ -18,7 +18,6 @@ require (
 require (
 	cloud.google.com/go v0.102.1 // indirect
 	cloud.google.com/go/compute v1.7.0 // indirect
-	github.com/common-nighthawk/go-figure v0.0.0-20210622060536-734e95fb86be // indirect
 	github.com/ctengiz/evtwebsocket v0.0.0-20180717104640-fc3583982591 // indirect
 	github.com/golang/groupcache v0.0.0-20200121045136-8c9f03a8e57e // indirect
 	github.com/golang/protobuf v1.5.2 // indirect
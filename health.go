@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/logger"
+)
+
+type healthState struct {
+	sync.Mutex
+	StartedAt         time.Time
+	WsConnected       bool
+	TgPollerRunning   bool
+	LastContactsCheck time.Time // last successful contacts check
+}
+
+type healthCheck struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+type healthReport struct {
+	Status                  string                 `json:"status"`
+	Checks                  map[string]healthCheck `json:"checks"`
+	ContactsCheckSecondsAgo int64                  `json:"contacts_check_seconds_ago"`
+	RestartStrategy         string                 `json:"restart_strategy"`
+	ReconnectState          string                 `json:"reconnect_state"`
+	EventsQueue             int                    `json:"events_queue"`
+}
+
+func newHealthCheck(err error) healthCheck {
+	if err != nil {
+		return healthCheck{OK: false, Error: err.Error()}
+	}
+	return healthCheck{OK: true}
+}
+
+func (h *healthState) setWsConnected(isConnected bool) {
+	h.Lock()
+	defer h.Unlock()
+	h.WsConnected = isConnected
+}
+
+func (h *healthState) setTgPollerRunning(isRunning bool) {
+	h.Lock()
+	defer h.Unlock()
+	h.TgPollerRunning = isRunning
+}
+
+func (h *healthState) onContactsChecked() {
+	h.Lock()
+	defer h.Unlock()
+	h.LastContactsCheck = time.Now()
+}
+
+// returns time since last contacts check or since start when there were no checks
+func (h *healthState) getContactsCheckDelay() time.Duration {
+	h.Lock()
+	defer h.Unlock()
+
+	if h.LastContactsCheck.IsZero() {
+		return time.Since(h.StartedAt)
+	}
+	return time.Since(h.LastContactsCheck)
+}
+
+// websocket & telegram poller state, used by readiness
+func (h *healthState) getConnectionChecks() map[string]healthCheck {
+	h.Lock()
+	defer h.Unlock()
+	return map[string]healthCheck{
+		"websocket": {OK: h.WsConnected},
+		"telegram":  {OK: h.TgPollerRunning},
+	}
+}
+
+// "fail" when any check is failed
+func getHealthStatus(checks map[string]healthCheck) string {
+	for _, check := range checks {
+		if !check.OK {
+			return "fail"
+		}
+	}
+	return "ok"
+}
+
+func (app *solution) getMaxContactsCheckDelay() time.Duration {
+	return time.Duration(app.getContactsCronTimeoutSeconds())*time.Second*healthContactsCheckMaxMissed +
+		healthStartupGracePeriod
+}
+
+func (app *solution) checkContactsCron() healthCheck {
+	if app.Health.getContactsCheckDelay() > app.getMaxContactsCheckDelay() {
+		return healthCheck{OK: false, Error: "contacts check is stuck"}
+	}
+	return healthCheck{OK: true}
+}
+
+func (app *solution) checkDB() healthCheck {
+	if app.DB == nil {
+		return healthCheck{OK: false, Error: "db is not connected"}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckRequestTimeout)
+	defer cancel()
+	return newHealthCheck(app.DB.Conn.PingContext(ctx))
+}
+
+func (app *solution) checkUtopia() healthCheck {
+	if !app.Config.UtopiaCfg.CheckClientConnection() {
+		return healthCheck{OK: false, Error: "failed to connect to " + app.Config.UtopiaCfg.Host}
+	}
+	return healthCheck{OK: true}
+}
+
+func (app *solution) getHealthReport(isReadiness bool) healthReport {
+	report := healthReport{
+		Checks: map[string]healthCheck{
+			"contacts_check": app.checkContactsCron(),
+		},
+		ContactsCheckSecondsAgo: int64(app.Health.getContactsCheckDelay().Seconds()),
+		RestartStrategy:         restarter.Name(),
+	}
+	if app.Reconnect != nil {
+		report.ReconnectState = app.Reconnect.getState()
+	}
+	if app.Events != nil {
+		report.EventsQueue = app.Events.getQueueDepth()
+	}
+
+	if isReadiness {
+		for name, check := range app.Health.getConnectionChecks() {
+			report.Checks[name] = check
+		}
+		report.Checks["utopia"] = app.checkUtopia()
+		report.Checks["db"] = app.checkDB()
+	}
+
+	report.Status = getHealthStatus(report.Checks)
+	return report
+}
+
+func (app *solution) writeHealthReport(w http.ResponseWriter, isReadiness bool) {
+	report := app.getHealthReport(isReadiness)
+
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		logger.Error(err)
+	}
+}
+
+// liveness: the bot is running & contacts check is not stuck
+func (app *solution) handleHealthzRequest(w http.ResponseWriter, r *http.Request) {
+	app.writeHealthReport(w, false)
+}
+
+// readiness: all connections are up
+func (app *solution) handleReadyzRequest(w http.ResponseWriter, r *http.Request) {
+	app.writeHealthReport(w, true)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealthContactsCheck(t *testing.T) {
+	app := solution{Health: &healthState{StartedAt: time.Now()}}
+	app.Config.ContactsCronPerMinute = 1
+
+	// no checks yet, startup grace period
+	if check := app.checkContactsCron(); !check.OK {
+		t.Fatalf("expected ok during startup, got %+v", check)
+	}
+
+	app.Health.StartedAt = time.Now().Add(-time.Hour)
+	if check := app.checkContactsCron(); check.OK {
+		t.Fatal("expected stuck contacts check without checks since start")
+	}
+
+	app.Health.onContactsChecked()
+	if check := app.checkContactsCron(); !check.OK {
+		t.Fatalf("expected ok after contacts check, got %+v", check)
+	}
+
+	app.Health.LastContactsCheck = time.Now().Add(-app.getMaxContactsCheckDelay() - time.Second)
+	if check := app.checkContactsCron(); check.OK {
+		t.Fatal("expected stuck contacts check")
+	}
+}
+
+func TestHealthConnectionChecks(t *testing.T) {
+	h := &healthState{}
+	checks := h.getConnectionChecks()
+	if checks["websocket"].OK || checks["telegram"].OK {
+		t.Fatalf("expected failed checks before connect, got %+v", checks)
+	}
+	if getHealthStatus(checks) != "fail" {
+		t.Fatal("readiness must fail without connections")
+	}
+
+	h.setWsConnected(true)
+	h.setTgPollerRunning(true)
+	if status := getHealthStatus(h.getConnectionChecks()); status != "ok" {
+		t.Fatalf("expected ok, got %q", status)
+	}
+
+	// websocket error
+	h.setWsConnected(false)
+	checks = h.getConnectionChecks()
+	if checks["websocket"].OK || !checks["telegram"].OK || getHealthStatus(checks) != "fail" {
+		t.Fatalf("expected failed websocket check, got %+v", checks)
+	}
+
+	if getHealthStatus(map[string]healthCheck{}) != "ok" {
+		t.Fatal("no checks must be ok")
+	}
+}
+
+func TestHealthzRequest(t *testing.T) {
+	app := solution{Health: &healthState{StartedAt: time.Now()}}
+	app.Config.ContactsCronPerMinute = 1
+
+	cases := []struct {
+		lastCheck time.Time
+		status    int
+	}{
+		{time.Now(), http.StatusOK},
+		{time.Now().Add(-time.Hour), http.StatusServiceUnavailable},
+	}
+	for _, c := range cases {
+		app.Health.LastContactsCheck = c.lastCheck
+
+		w := httptest.NewRecorder()
+		app.handleHealthzRequest(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		if w.Code != c.status {
+			t.Fatalf("expected status %v, got %v", c.status, w.Code)
+		}
+
+		report := healthReport{}
+		if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+			t.Fatal(err)
+		}
+		if _, isFound := report.Checks["websocket"]; isFound {
+			t.Fatal("liveness must not check connections")
+		}
+	}
+}
@@ -3,7 +3,6 @@ package main
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io/ioutil"
 	"math/rand"
 	"os"
@@ -11,7 +10,6 @@ import (
 	"strings"
 	"time"
 
-	swissknife "github.com/Sagleft/swiss-knife"
 	"github.com/beefsack/go-rate"
 	"github.com/fatih/color"
 	"github.com/google/logger"
@@ -41,7 +39,7 @@ func (app *solution) parseConfig() error {
 	if err != nil {
 		return err
 	}
-	err = json.Unmarshal(jsonBytes, app.Config)
+	err = json.Unmarshal(jsonBytes, &app.Config)
 	if err != nil {
 		return err
 	}
@@ -50,11 +48,24 @@ func (app *solution) parseConfig() error {
 		Client: &app.Config.UtopiaCfg,
 		RateLimiter: rate.New(
 			limitMaxUserResponsesPerSecond,
-			time.Duration(app.Config.UserMessageRateTimeoutMs)*time.Hour,
+			time.Duration(app.Config.UserMessageRateTimeoutMs)*time.Millisecond,
 		),
 	}
+	app.InboundLimiter = newInboundLimiter(app.Config.InboundLimit)
 
 	autoRebootDisabled = app.Config.AutoRebootDisabled
+	restarter, err = newRestartStrategy(app.Config.Restart, osCommandExecutor{})
+	if err != nil {
+		return err
+	}
+
+	if app.Config.LocalesDir == "" {
+		app.Config.LocalesDir = defaultLocalesDir
+	}
+	if app.Config.DefaultLanguage == "" {
+		app.Config.DefaultLanguage = defaultLanguage
+	}
+
 	tips = app.Config.Tips
 	return nil
 }
@@ -67,31 +78,6 @@ func printSuccess(info string) {
 	color.Green(wrapPrintedMessage(info))
 }
 
-func (app *solution) getPointsByPeriod(usersOnline int) float64 {
-	var pointsPer24h float64 = 0
-
-	if app.Config.UseIntervals {
-		// find users online value from intervals
-		// value = points by 1h
-		var pointsBy1h float64 = 0
-		for i := 0; i < len(app.Config.Intervals); i++ {
-			interval := app.Config.Intervals[i]
-			if usersOnline >= interval.From && usersOnline <= interval.To {
-				pointsBy1h = interval.Value
-			}
-		}
-		if pointsBy1h == 0 {
-			logger.Error("interval not found to get points per 24h")
-		} else {
-			pointsPer24h = pointsBy1h * 24
-		}
-	} else {
-		pointsPer24h = app.Config.PointsPer24h
-	}
-
-	return pointsPer24h / (24 * 60 * 60 / float64(app.getContactsCronTimeoutSeconds()))
-}
-
 func formatFloat(val float64) string {
 	result := strconv.FormatFloat(val, 'f', 4, 32)
 	return strings.TrimRight(strings.TrimRight(result, "0"), ".")
@@ -110,8 +96,9 @@ func getRandomTgEmoji() string {
 }
 
 func LimitStringLength(str string, maxLength int) string {
-	if len(str) > maxLength {
-		return str[:maxLength] + ".."
+	runes := []rune(str)
+	if len(runes) > maxLength {
+		return string(runes[:maxLength]) + ".."
 	}
 	return str
 }
@@ -122,13 +109,37 @@ func filterNickname(nickname string) string {
 	return LimitStringLength(nickname, nicknameMaxLength)
 }
 
-func (app *solution) genGameVoucher() string {
-	return fmt.Sprintf(
-		gameVoucherTemplate,
-		app.Config.GameVoucherPrefix,
-		strings.ToUpper(swissknife.GetRandomString(2)),
-		strings.ToUpper(swissknife.GetRandomString(4)),
-		strings.ToUpper(swissknife.GetRandomString(4)),
-		strings.ToUpper(swissknife.GetRandomString(4)),
-	)
+// parses TTL like 3d, 72h or 30m. "-" means no expiry.
+// returns unix timestamp or 0 for no expiry
+func parseExpiryTime(raw string) (int64, error) {
+	if raw == "-" {
+		return 0, nil
+	}
+
+	var ttl time.Duration
+	var err error
+	if strings.HasSuffix(raw, "d") {
+		var days int
+		days, err = strconv.Atoi(strings.TrimSuffix(raw, "d"))
+		ttl = time.Duration(days) * time.Hour * 24
+	} else {
+		ttl, err = time.ParseDuration(raw)
+	}
+	if err != nil || ttl <= 0 {
+		return 0, errors.New("invalid expiry, use format like 3d, 72h or 30m")
+	}
+	return time.Now().Add(ttl).Unix(), nil
+}
+
+// utopia pubkey is 64 hex symbols
+func isPubkeyValid(pubkey string) bool {
+	if len(pubkey) != 64 {
+		return false
+	}
+	for _, r := range pubkey {
+		if !strings.ContainsRune("0123456789ABCDEFabcdef", r) {
+			return false
+		}
+	}
+	return true
 }
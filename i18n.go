@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/google/logger"
+)
+
+// localized messages: lang -> key -> fmt template
+type i18nCatalog struct {
+	DefaultLang string
+	Messages    map[string]map[string]string
+}
+
+type languagesCache struct {
+	sync.RWMutex
+	Data map[string]string // pubkey -> lang
+}
+
+func newI18nCatalog(defaultLang string) *i18nCatalog {
+	return &i18nCatalog{
+		DefaultLang: defaultLang,
+		Messages:    map[string]map[string]string{},
+	}
+}
+
+// loads <lang>.json files from dir
+func loadI18nCatalog(dir, defaultLang string) (*i18nCatalog, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	c := newI18nCatalog(defaultLang)
+	for _, path := range files {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, errors.New("failed to read locale file: " + err.Error())
+		}
+
+		messages := map[string]string{}
+		if err := json.Unmarshal(data, &messages); err != nil {
+			return nil, errors.New("failed to decode " + path + ": " + err.Error())
+		}
+		c.Messages[strings.TrimSuffix(filepath.Base(path), ".json")] = messages
+	}
+
+	if !c.has(defaultLang) {
+		return nil, errors.New("default language `" + defaultLang + "` not found in " + dir)
+	}
+	return c, nil
+}
+
+func (c *i18nCatalog) has(lang string) bool {
+	_, isFound := c.Messages[lang]
+	return isFound
+}
+
+func (c *i18nCatalog) getLanguages() []string {
+	langs := []string{}
+	for lang := range c.Messages {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// falls back to the default language and then to the key itself
+func (c *i18nCatalog) T(lang, key string, args ...interface{}) string {
+	template, isFound := c.Messages[lang][key]
+	if !isFound {
+		template, isFound = c.Messages[c.DefaultLang][key]
+	}
+	if !isFound {
+		template = key
+	}
+
+	if len(args) == 0 {
+		return template
+	}
+	return fmt.Sprintf(template, args...)
+}
+
+func tr(lang, key string, args ...interface{}) string {
+	return catalog.T(lang, key, args...)
+}
+
+// returns "ru" or "en" by dominant alphabet, or "" when text has no letters
+func detectLanguage(text string) string {
+	cyrillic, latin := 0, 0
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		}
+	}
+
+	switch {
+	case cyrillic == 0 && latin == 0:
+		return ""
+	case cyrillic >= latin:
+		return "ru"
+	default:
+		return "en"
+	}
+}
+
+func (app *solution) initLanguages() error {
+	logger.Info("load locales..")
+
+	var err error
+	catalog, err = loadI18nCatalog(app.Config.LocalesDir, app.Config.DefaultLanguage)
+	if err != nil {
+		return err
+	}
+
+	languages, err := app.DB.getUserLanguages()
+	if err != nil {
+		return err
+	}
+
+	app.Languages.Lock()
+	defer app.Languages.Unlock()
+	for pubkey, lang := range languages {
+		app.Languages.Data[pubkey] = lang
+	}
+	return nil
+}
+
+// returns user language and false if it was never chosen or detected
+func (app *solution) getUserLanguage(pubkey string) (string, bool) {
+	app.Languages.RLock()
+	lang, isFound := app.Languages.Data[pubkey]
+	app.Languages.RUnlock()
+
+	if !isFound || !catalog.has(lang) {
+		return catalog.DefaultLang, false
+	}
+	return lang, true
+}
+
+func (app *solution) setUserLanguage(pubkey, lang string) error {
+	if err := app.DB.saveUserLanguage(pubkey, lang); err != nil {
+		return err
+	}
+
+	app.Languages.Lock()
+	app.Languages.Data[pubkey] = lang
+	app.Languages.Unlock()
+	return nil
+}
+
+// detects language by the first user message
+func (app *solution) detectUserLanguage(pubkey, messageText string) string {
+	lang := detectLanguage(messageText)
+	if lang == "" || !catalog.has(lang) {
+		return catalog.DefaultLang
+	}
+
+	if err := app.setUserLanguage(pubkey, lang); err != nil {
+		logger.Error(err)
+	}
+	return lang
+}
+
+// returns reply in the selected language
+func (app *solution) handleLanguageCommand(pubkey, lang string, args []string) string {
+	if len(args) == 0 || !catalog.has(args[0]) {
+		return tr(lang, "user.language_usage", strings.Join(catalog.getLanguages(), ", "))
+	}
+
+	if err := app.setUserLanguage(pubkey, args[0]); err != nil {
+		logger.Error(err)
+		return tr(lang, "user.request_error")
+	}
+	return tr(args[0], "user.language_changed", tr(args[0], "language.name"))
+}
+
+// config texts are written in the default language,
+// other languages take them from the catalog
+func (app *solution) getConfigText(lang, key, configText string) string {
+	if lang == catalog.DefaultLang || configText == "" {
+		return configText
+	}
+	if _, isFound := catalog.Messages[lang][key]; !isFound {
+		return configText
+	}
+	return tr(lang, key)
+}
+
+// returns telegram client language if it is supported
+func getTelegramLanguage(languageCode string) string {
+	lang := strings.ToLower(strings.SplitN(languageCode, "-", 2)[0])
+	if !catalog.has(lang) {
+		return catalog.DefaultLang
+	}
+	return lang
+}
@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+func TestI18nCatalog(t *testing.T) {
+	c, err := loadI18nCatalog(defaultLocalesDir, defaultLanguage)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, lang := range c.getLanguages() {
+		for key := range c.Messages[defaultLanguage] {
+			if _, isFound := c.Messages[lang][key]; !isFound {
+				t.Errorf("%s: key %q is not translated", lang, key)
+			}
+		}
+	}
+
+	if msg := c.T("en", "mod.user_balance", "10"); msg != "User balance: 10 p" {
+		t.Fatalf("unexpected translation: %q", msg)
+	}
+	if msg := c.T("de", "mod.user_not_found"); msg != "Пользователь не найден" {
+		t.Fatalf("expected default language fallback, got %q", msg)
+	}
+	if msg := c.T("en", "unknown.key"); msg != "unknown.key" {
+		t.Fatalf("expected key fallback, got %q", msg)
+	}
+
+	if _, err := loadI18nCatalog(defaultLocalesDir, "de"); err == nil {
+		t.Fatal("expected error for missing default language")
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	cases := map[string]string{
+		"привет, как вывести баллы?": "ru",
+		"hello, how to withdraw?":    "en",
+		"баланс UT-V":                "ru",
+		"123 ???":                    "",
+	}
+	for text, expected := range cases {
+		if lang := detectLanguage(text); lang != expected {
+			t.Errorf("%q: expected %q, got %q", text, expected, lang)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/logger"
+)
+
+// per-user inbound messages limit with sliding window
+type inboundLimiter struct {
+	sync.Mutex
+	Config      inboundLimitConfig
+	Users       map[string]*inboundUserState // pubkey -> state
+	LastCleanup time.Time
+}
+
+type inboundUserState struct {
+	Messages    []time.Time // messages in the current window
+	MutedUntil  time.Time
+	Mutes       []time.Time // mutes in the escalation window
+	EscalatedAt time.Time
+}
+
+type inboundVerdict struct {
+	IsAllowed    bool
+	IsJustMuted  bool // the user must be told about the mute
+	MuteDuration time.Duration
+	Escalate     bool // notify moderators about the spammer
+	MutesCount   int
+}
+
+func newInboundLimiter(cfg inboundLimitConfig) *inboundLimiter {
+	if cfg.MaxMessages <= 0 {
+		cfg.MaxMessages = defaultInboundMaxMessages
+	}
+	if cfg.WindowSeconds <= 0 {
+		cfg.WindowSeconds = defaultInboundWindowSeconds
+	}
+	if cfg.MuteMinutes <= 0 {
+		cfg.MuteMinutes = defaultInboundMuteMinutes
+	}
+	if cfg.EscalateAfterMutes <= 0 {
+		cfg.EscalateAfterMutes = defaultInboundEscalateAfterMutes
+	}
+
+	return &inboundLimiter{
+		Config: cfg,
+		Users:  map[string]*inboundUserState{},
+	}
+}
+
+func (l *inboundLimiter) getWindow() time.Duration {
+	return time.Duration(l.Config.WindowSeconds) * time.Second
+}
+
+func filterTimesAfter(times []time.Time, from time.Time) []time.Time {
+	result := []time.Time{}
+	for _, t := range times {
+		if t.After(from) {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+func (l *inboundLimiter) check(pubkey string, now time.Time) inboundVerdict {
+	l.Lock()
+	defer l.Unlock()
+	l.cleanup(now)
+
+	state, isFound := l.Users[pubkey]
+	if !isFound {
+		state = &inboundUserState{}
+		l.Users[pubkey] = state
+	}
+
+	if now.Before(state.MutedUntil) {
+		return inboundVerdict{IsAllowed: false}
+	}
+
+	state.Messages = append(filterTimesAfter(state.Messages, now.Add(-l.getWindow())), now)
+	if len(state.Messages) <= l.Config.MaxMessages {
+		return inboundVerdict{IsAllowed: true}
+	}
+
+	// mute duration doubles for repeated mutes
+	state.Mutes = append(filterTimesAfter(state.Mutes, now.Add(-inboundEscalationWindow)), now)
+	muteDuration := time.Duration(l.Config.MuteMinutes) * time.Minute << (len(state.Mutes) - 1)
+	if muteDuration > inboundMaxMuteDuration {
+		muteDuration = inboundMaxMuteDuration
+	}
+	state.MutedUntil = now.Add(muteDuration)
+	state.Messages = nil
+
+	verdict := inboundVerdict{
+		IsJustMuted:  true,
+		MuteDuration: muteDuration,
+		MutesCount:   len(state.Mutes),
+	}
+	if len(state.Mutes) >= l.Config.EscalateAfterMutes &&
+		now.Sub(state.EscalatedAt) > inboundEscalationWindow {
+		state.EscalatedAt = now
+		verdict.Escalate = true
+	}
+	return verdict
+}
+
+// removes inactive users
+func (l *inboundLimiter) cleanup(now time.Time) {
+	if now.Sub(l.LastCleanup) < inboundCleanupInterval {
+		return
+	}
+	l.LastCleanup = now
+
+	for pubkey, state := range l.Users {
+		isActive := now.Before(state.MutedUntil) ||
+			len(filterTimesAfter(state.Messages, now.Add(-l.getWindow()))) > 0 ||
+			len(filterTimesAfter(state.Mutes, now.Add(-inboundEscalationWindow))) > 0
+		if !isActive {
+			delete(l.Users, pubkey)
+		}
+	}
+}
+
+// returns false when the message must be ignored
+func (app *solution) checkInboundLimit(pubkey, nick string) bool {
+	if !app.Config.InboundLimit.Enabled || app.isUserModerator(pubkey) {
+		return true
+	}
+
+	verdict := app.InboundLimiter.check(pubkey, time.Now())
+	if verdict.IsAllowed {
+		return true
+	}
+	if !verdict.IsJustMuted {
+		return false
+	}
+
+	logger.Warning("user " + pubkey + " muted for " + verdict.MuteDuration.String())
+	lang, _ := app.getUserLanguage(pubkey)
+	msg := tr(lang, "user.muted", verdict.MuteDuration.String())
+	if err := app.sendMessage(pubkey, msg); err != nil {
+		logger.Error(err)
+	}
+
+	if verdict.Escalate {
+		app.notifyModerators(tr(catalog.DefaultLang, "inbound.mute_escalation",
+			nick, pubkey, verdict.MutesCount, inboundEscalationWindow.String(), pubkey,
+		))
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInboundLimiter(t *testing.T) {
+	l := newInboundLimiter(inboundLimitConfig{
+		MaxMessages:        3,
+		WindowSeconds:      10,
+		MuteMinutes:        1,
+		EscalateAfterMutes: 2,
+	})
+	now := time.Now()
+
+	// sliding window: old messages are not counted
+	for i := 0; i < 6; i++ {
+		if v := l.check("user", now.Add(time.Duration(i)*4*time.Second)); !v.IsAllowed {
+			t.Fatalf("message %v: expected allowed", i)
+		}
+	}
+
+	now = now.Add(time.Minute)
+	for i := 0; i < 3; i++ {
+		l.check("user", now)
+	}
+	v := l.check("user", now)
+	if v.IsAllowed || !v.IsJustMuted || v.MuteDuration != time.Minute || v.Escalate {
+		t.Fatalf("expected the first mute for 1m, got %+v", v)
+	}
+	if v := l.check("user", now.Add(30*time.Second)); v.IsAllowed || v.IsJustMuted {
+		t.Fatalf("expected silent ignore while muted, got %+v", v)
+	}
+	if v := l.check("other", now); !v.IsAllowed {
+		t.Fatal("other users must not be limited")
+	}
+
+	now = now.Add(2 * time.Minute)
+	for i := 0; i < 3; i++ {
+		l.check("user", now)
+	}
+	v = l.check("user", now)
+	if v.MuteDuration != 2*time.Minute || !v.Escalate {
+		t.Fatalf("expected the second mute for 2m with escalation, got %+v", v)
+	}
+}
@@ -6,11 +6,10 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
-	dialogflow "cloud.google.com/go/dialogflow/apiv2"
 	utopiago "github.com/Sagleft/utopialib-go"
 	"github.com/google/logger"
-	dialogflowpb "google.golang.org/genproto/googleapis/cloud/dialogflow/v2"
 )
 
 /*
@@ -31,7 +30,7 @@ import (
     "type": "newInstantMessage"
 }
 */
-func (app *solution) onUserMessage(event utopiago.WsEvent) {
+func (app *solution) onUserMessage(ctx context.Context, event utopiago.WsEvent) {
 	isMessageIncoming, err := event.GetBool("isIncoming")
 	if err != nil {
 		logger.Error(err)
@@ -60,22 +59,82 @@ func (app *solution) onUserMessage(event utopiago.WsEvent) {
 		return
 	}
 
-	// если это игровой ваучер, который прислан без команд
-	if len(messageText) == gameVoucherLength || strings.Contains(messageText, app.Config.GameVoucherPrefix) {
+	// the message can come from websocket and from resync after reconnect.
+	// messages without ID can't be deduplicated, they are handled as new
+	if messageID, err := getEventMessageID(event); err != nil {
+		logger.Warning(err.Error())
+	} else {
+		isNewMessage, err := app.DB.markMessageProcessed(ctx, userPubkey, messageID)
+		if err != nil {
+			logger.Error(err)
+			return
+		}
+		if !isNewMessage {
+			return
+		}
+	}
+
+	lang, isLangSet := app.getUserLanguage(userPubkey)
 
-		if !app.isVoucherCanBeActivated(userPubkey) {
-			if err := app.sendMessage(userPubkey, "ваучер уже был активирован или не существует"); err != nil {
+	switch app.getUserRestriction(userPubkey) {
+	case restrictionBlock:
+		return
+	case restrictionBan:
+		if app.Config.BannedMessage != "" {
+			msg := app.getConfigText(lang, "user.banned_message", app.Config.BannedMessage)
+			if err := app.sendMessage(userPubkey, msg); err != nil {
+				app.onUtopiaError(err)
+			}
+		}
+		return
+	}
+
+	// restricted users are not limited: no mute replies & escalations for them
+	if !app.checkInboundLimit(userPubkey, nick) {
+		return
+	}
+
+	// если это игровой ваучер, который прислан без команд
+	if voucherCode, isVoucher := app.VoucherFormat.find(messageText); isVoucher {
+		if !app.VoucherFormat.isLegacy(voucherCode) && !app.VoucherFormat.isChecksumValid(voucherCode) {
+			// typo, no need to check the code in db
+			msg := tr(lang, "user.voucher_typo")
+			if err := app.sendMessage(userPubkey, msg); err != nil {
 				app.onUtopiaError(err)
-				return
 			}
 			return
 		}
 
-		voucherAmount, err := app.activateGameVoucher(userPubkey, messageText)
+		attempts, err := app.DB.getVoucherAttempts(userPubkey)
 		if err != nil {
 			logger.Error(err)
-			msg := "Произошла ошибка при активации ваучера.\n" +
-				"Можешь связаться с менеджером, сообщив дату и время ошибки"
+			if err := app.sendMessage(userPubkey, tr(lang, "user.request_error")); err != nil {
+				app.onUtopiaError(err)
+			}
+			return
+		}
+
+		if attempts.isLocked(time.Now()) {
+			msg := tr(lang, "user.voucher_locked", attempts.getLockRemaining(time.Now()).String())
+			if err := app.sendMessage(userPubkey, msg); err != nil {
+				app.onUtopiaError(err)
+			}
+			return
+		}
+
+		voucherAmount, err := app.activateGameVoucher(ctx, userPubkey, voucherCode)
+		if err != nil {
+			msg := tr(lang, "user.voucher_activation_error")
+			switch {
+			default:
+				logger.Error(err)
+			case errors.Is(err, errVoucherExpired):
+				msg = tr(lang, "user.voucher_expired")
+			case errors.Is(err, errVoucherUserLimit):
+				msg = tr(lang, "user.voucher_user_limit")
+			case errors.Is(err, errVoucherBudget):
+				msg = tr(lang, "user.voucher_budget_spent")
+			}
 			if err := app.sendMessage(userPubkey, msg); err != nil {
 				app.onUtopiaError(err)
 				return
@@ -84,30 +143,52 @@ func (app *solution) onUserMessage(event utopiago.WsEvent) {
 		}
 
 		if voucherAmount == 0 {
-			if err := app.sendMessage(userPubkey, "ваучер уже был активирован или не существует"); err != nil {
+			app.onVoucherActivationFailed(attempts, nick)
+			if err := app.sendMessage(userPubkey, tr(lang, "user.voucher_not_found")); err != nil {
 				app.onUtopiaError(err)
 				return
 			}
 			return
 		}
 
-		msg := fmt.Sprintf("OK! Ваучер был активирован\nНачислено +%v баллов", voucherAmount)
-		if err := app.sendMessage(userPubkey, msg); err != nil {
+		app.onVoucherActivated(attempts)
+		botMetrics.VoucherRedemptions.inc()
+
+		msg := tr(lang, "user.voucher_activated", voucherAmount)
+		if err := app.sendMessageWithPriority(userPubkey, msg, messagePriorityHigh); err != nil {
 			app.onUtopiaError(err)
-			return
 		}
+
+		app.onGiveawayVoucherActivated(voucherCode, nick)
+		return
+	}
+
+	if !isLangSet {
+		// voucher codes are not used for detection, they are latin for everyone
+		lang = app.detectUserLanguage(userPubkey, messageText)
+	}
+
+	command, args := app.findUserCommand(messageText)
+	commandTask := userCommandTask{
+		Pubkey: userPubkey,
+		Nick:   filterNickname(nick),
+		Lang:   lang,
+		Args:   args,
+	}
+	if command != nil && command.IsForModerators {
+		app.runUserCommand(command, commandTask)
 		return
 	}
 
 	if app.isUserModerator(userPubkey) {
 		// moderator request
-		messages, err := app.handleModeratorRequest(messageText, false, 0)
+		messages, err := app.handleModeratorRequest(ctx, lang, messageText, false, 0)
 		if err != nil {
 			logger.Error(err)
 		}
 
 		for i := 0; i < len(messages); i++ {
-			err = app.sendMessageWithoutLock(userPubkey, messages[i])
+			err = app.sendMessageWithPriority(userPubkey, messages[i], messagePriorityHigh)
 			if err != nil {
 				app.onUtopiaError(err)
 			}
@@ -119,15 +200,23 @@ func (app *solution) onUserMessage(event utopiago.WsEvent) {
 	userData, err := app.DB.getUserData(userPubkey, filterNickname(nick))
 	if err != nil {
 		logger.Error(err)
-		if err := app.sendMessage(userPubkey, "ошибка обработки запроса"); err != nil {
+		if err := app.sendMessage(userPubkey, tr(lang, "user.request_error")); err != nil {
 			app.onUtopiaError(err)
 			return
 		}
 		return
 	}
 
+	ticket, err := app.getActiveSupportTicket(userPubkey)
+	if err != nil {
+		logger.Error(err)
+	} else if ticket != nil {
+		app.handleSupportUserMessage(ticket, lang, messageText)
+		return
+	}
+
 	if len(messageText) < 3 {
-		err = app.sendMessage(userPubkey, "Сообщение слишком короткое")
+		err = app.sendMessage(userPubkey, tr(lang, "user.message_too_short"))
 		if err != nil {
 			app.onUtopiaError(err)
 			return
@@ -136,27 +225,16 @@ func (app *solution) onUserMessage(event utopiago.WsEvent) {
 	}
 
 	// КОМАНДЫ ЮЗВЕРЯ
-	messageText = strings.TrimSpace(messageText)
-	messageText = strings.ToLower(messageText)
-	replyMessage := ""
-	switch messageText {
-	default:
-		replyMessage, err = app.handleUnknownUserMessage(messageText)
-		if err != nil {
-			logger.Error(err)
-			if err := app.sendMessage(userPubkey, "не удалось обработать запрос"); err != nil {
-				app.onUtopiaError(err)
-				return
-			}
-			return
-		}
-	case comandBalance:
-		replyMessage = app.getUserBalance(userData)
-	case comandBalance2:
-		replyMessage = app.getUserBalance(userData)
-	case comandManager:
-		replyMessage = "Чтобы вывести баллы, можно писать: " + app.Config.RequestsModeratorPubkey + "\n" +
-			"Или в телеграме - " + app.Config.ModeratorTelegram
+	if command != nil {
+		commandTask.User = userData
+		app.runUserCommand(command, commandTask)
+		return
+	}
+
+	replyMessage, err := app.handleUnknownUserMessage(ctx, userPubkey, lang, strings.ToLower(strings.TrimSpace(messageText)))
+	if err != nil {
+		logger.Error(err)
+		replyMessage = tr(lang, "user.request_failed")
 	}
 
 	err = app.sendMessage(userPubkey, replyMessage)
@@ -166,89 +244,63 @@ func (app *solution) onUserMessage(event utopiago.WsEvent) {
 }
 
 // returns voucher amount
-func (app *solution) activateGameVoucher(userPubkey, voucherCode string) (float64, error) {
-	amount, err := app.DB.getGameVoucherAmount(voucherCode)
-	if err != nil {
-		return 0, err
-	}
-	if amount == 0 {
-		return 0, nil
-	}
+func (app *solution) activateGameVoucher(ctx context.Context, userPubkey, voucherCode string) (float64, error) {
+	app.Budget.VouchersLock.Lock()
+	defer app.Budget.VouchersLock.Unlock()
 
-	if err := app.DB.addUserPoints(amount, userPubkey); err != nil {
-		return 0, err
+	budgetLeft := app.getBudgetRemaining()
+	amount, err := app.DB.activateGameVoucher(ctx, userPubkey, voucherCode, budgetLeft)
+	if err == nil && amount == 0 {
+		// not a single-use voucher, check batches
+		amount, err = app.DB.activateBatchVoucher(ctx, userPubkey, voucherCode, budgetLeft)
 	}
-
-	return amount, app.DB.deleteGameVoucher(voucherCode)
+	if err == nil && amount > 0 {
+		app.chargeVoucher(amount)
+	}
+	return amount, err
 }
 
-func (app *solution) getUserBalance(userData *userData) string {
-	return ""
-
-	replyMessage := "Текущий баланс: " + formatFloat(userData.Balance) + " баллов.\n" +
-		"Минимальный вывод: " + formatFloat(app.Config.MinWithdraw) + "."
+func (app *solution) getUserBalance(lang string, userData *userData) string {
+	replyMessage := tr(lang, "user.balance", formatFloat(userData.Balance), formatFloat(app.Config.MinWithdraw))
 
 	if userData.Balance >= app.Config.MinWithdraw {
-		replyMessage += "\n\nДля вывода средств необходимо связаться с " + app.Config.RequestsModeratorPubkey + "\n\n" +
-			"Или в телеграм: " + app.Config.ModeratorTelegram
+		replyMessage += tr(lang, "user.balance_withdraw")
 	}
 
-	replyMessage += "\n\n[forefinger] " + getRandomTip()
+	// tips are written in the default language
+	if lang == catalog.DefaultLang {
+		replyMessage += "\n\n[forefinger] " + getRandomTip()
+	}
 	return replyMessage
 }
 
-func (app *solution) handleUnknownUserMessage(messageText string) (string, error) {
-	var err error
-	var replyMessage string
-
-	if app.Config.DialogflowEnabled {
-		replyMessage, err = app.handleDialogFlowMessage(messageText)
-	} else {
-		replyMessage = app.Config.InvalidMessage
+func (app *solution) handleUnknownUserMessage(ctx context.Context, pubkey, lang, messageText string) (string, error) {
+	if answer := app.findFAQAnswer(lang, messageText); answer != "" {
+		botMetrics.NLUAnswers.addWithLabel("faq", 1)
+		return answer, nil
 	}
 
-	return replyMessage, err
-}
-
-func (app *solution) handleDialogFlowMessage(messageText string) (string, error) {
-	return DetectIntentText(
-		app.Config.DialogflowProjectID,
-		dialogFlowSessionID,
-		messageText,
-		app.Config.DialogflowLandcode,
-	)
-}
-
-func DetectIntentText(projectID, sessionID, text, languageCode string) (string, error) {
-	ctx := context.Background()
-
-	sessionClient, err := dialogflow.NewSessionsClient(ctx)
+	result, backend, err := app.NLU.DetectIntent(ctx, getNLUSessionID(pubkey), lang, messageText)
 	if err != nil {
 		return "", err
 	}
-	defer sessionClient.Close()
-
-	if projectID == "" || sessionID == "" {
-		return "", fmt.Errorf("received empty project (%s) or session (%s)", projectID, sessionID)
-	}
-
-	sessionPath := fmt.Sprintf("projects/%s/agent/sessions/%s", projectID, sessionID)
-	textInput := dialogflowpb.TextInput{Text: text, LanguageCode: languageCode}
-	queryTextInput := dialogflowpb.QueryInput_Text{Text: &textInput}
-	queryInput := dialogflowpb.QueryInput{Input: &queryTextInput}
-	request := dialogflowpb.DetectIntentRequest{Session: sessionPath, QueryInput: &queryInput}
-
-	response, err := sessionClient.DetectIntent(ctx, &request)
-	if err != nil {
-		return "", err
+	if result.Answer != "" {
+		botMetrics.NLUAnswers.addWithLabel(backend, 1)
+		return result.Answer, nil
 	}
 
-	queryResult := response.GetQueryResult()
-	fulfillmentText := queryResult.GetFulfillmentText()
-	return fulfillmentText, nil
+	botMetrics.NLUAnswers.addWithLabel("none", 1)
+	app.saveUnansweredQuestion(pubkey, lang, messageText)
+	return app.getConfigText(lang, "user.invalid_message", app.Config.InvalidMessage) + tr(lang, "user.help_hint"), nil
 }
 
+// queues message with normal priority
 func (app *solution) sendMessage(pubkey string, text string) error {
+	return app.sendMessageWithPriority(pubkey, text, messagePriorityNormal)
+}
+
+// sends message right now, used by outbound queue
+func (app *solution) deliverMessage(pubkey string, text string) error {
 	if app.Config.SyncUserResponses {
 		return app.sendMessageWithLock(pubkey, text)
 	}
@@ -266,6 +318,9 @@ func (app *solution) sendMessageWithLock(pubkey string, text string) error {
 
 	// send message
 	_, err := app.Config.UtopiaCfg.SendInstantMessage(pubkey, text)
+	if err == nil {
+		botMetrics.MessagesSent.inc()
+	}
 	return err
 }
 
@@ -274,139 +329,241 @@ func (app *solution) sendMessageWithoutLock(pubkey string, text string) error {
 	app.MessageHandler.RateLimiter.Wait()
 
 	_, err := app.Config.UtopiaCfg.SendInstantMessage(pubkey, text)
+	if err == nil {
+		botMetrics.MessagesSent.inc()
+	}
 	return err
 }
 
 // КОМАНДЫ МОДЕРАТОРА
 func (app *solution) handleModeratorRequest(
-	messageText string, fromTelegram bool, telegramUserID int64,
+	ctx context.Context, lang string, messageText string, fromTelegram bool, telegramUserID int64,
 ) ([]string, error) {
 	if messageText == "" {
-		return []string{"пустое сообщение"}, nil
+		return []string{tr(lang, "mod.empty_message")}, nil
 	}
 
 	messageText = strings.TrimSpace(messageText)
 	msgParts := strings.Split(messageText, " ")
 
 	if len(msgParts) == 0 {
-		return []string{"Запрос должен содержать 2 части через пробел:\n\n"}, nil
+		return []string{getUsageMessage(lang, 2, "")}, nil
 	}
 
 	command := strings.ToLower(msgParts[0])
 	switch command {
 	default:
-		msgs := []string{"Я не знаю команды `" + command + "`\n\n"}
+		msgs := []string{tr(lang, "mod.unknown_command", command)}
 		if fromTelegram {
 			msgs = append(msgs, app.getMessages())
 		}
 		return msgs, nil
 	case "логи":
 		if len(msgParts) < 2 {
-			return []string{"Запрос должен содержать 2 части через пробел:\n\nлоги <публичный ключ>"}, nil
+			return []string{getUsageMessage(lang, 2, tr(lang, "mod.usage.logs"))}, nil
 		}
 
-		return []string{}, app.getLogsByUser(msgParts[1], telegramUserID)
+		return []string{}, app.getLogsByUser(lang, msgParts[1], telegramUserID)
 	case "сброс":
 		if len(msgParts) < 2 {
-			return []string{"Запрос должен содержать 2 части через пробел:\n\nсброс <публичный ключ>"}, nil
+			return []string{getUsageMessage(lang, 2, tr(lang, "mod.usage.reset"))}, nil
 		}
-		r, err := app.resetUserPoints(msgParts[1])
+		r, err := app.resetUserPoints(ctx, lang, msgParts[1])
 		if err != nil {
 			return []string{}, err
 		}
 		return []string{r}, nil
 	case "баланс":
 		if len(msgParts) < 2 {
-			return []string{"Запрос должен содержать 2 части через пробел:\n\n"}, nil
+			return []string{getUsageMessage(lang, 2, "")}, nil
 		}
-		r, err := app.viewUserBalance(msgParts[1])
+		r, err := app.viewUserBalance(lang, msgParts[1])
 		if err != nil {
 			return []string{}, err
 		}
 		return []string{r}, nil
 	case "вычет":
 		if len(msgParts) < 2 {
-			return []string{"Запрос должен содержать 2 части через пробел:\n\n" +
-				"команда данные"}, nil
+			return []string{getUsageMessage(lang, 2, tr(lang, "mod.usage.decrease"))}, nil
 		}
 		pointsRaw := ""
 		if len(msgParts) >= 3 {
 			pointsRaw = msgParts[2]
 		}
-		r, err := app.decreaseUserPoints(msgParts[1], pointsRaw)
+		r, err := app.decreaseUserPoints(ctx, lang, msgParts[1], pointsRaw)
 		if err != nil {
 			return []string{}, err
 		}
 		return []string{r}, nil
 	case "онлайн":
-		return app.handleUsersOnlineRequest(fromTelegram)
+		return app.handleUsersOnlineRequest(lang, fromTelegram)
 
 	case "ваучер":
 		if len(msgParts) < 2 {
-			return []string{"Запрос должен содержать 2 части через пробел:\n\n" +
-				"ваучер сумма\n\nНапример:\n\n" +
-				"ваучер 50"}, nil
+			return []string{getUsageMessage(lang, 2, tr(lang, "mod.usage.voucher"))}, nil
 		}
-		return app.handleCreateVoucherRequest(msgParts[1])
+		return app.handleCreateVoucherRequest(lang, msgParts[1])
 
 	case "погасить":
 		if len(msgParts) < 2 {
-			return []string{"Запрос должен содержать 2 части через пробел:\n\n" +
-				"погасить <код>\n\nНапример:\n\n" +
-				"погасить " + app.genGameVoucher()}, nil
+			return []string{getUsageMessage(lang, 2,
+				tr(lang, "mod.usage.redeem", app.VoucherFormat.Prefix+app.VoucherFormat.Template))}, nil
+		}
+		return app.handleVoucherDelete(lang, msgParts[1])
+
+	case "пакет":
+		if len(msgParts) < 6 {
+			return []string{getUsageMessage(lang, 6, tr(lang, "mod.usage.batch"))}, nil
+		}
+		return app.handleCreateVoucherBatchRequest(lang, msgParts[1:], fromTelegram, telegramUserID)
+
+	case "пакеты":
+		return app.handleVoucherBatchesList(lang)
+
+	case "отозвать":
+		if len(msgParts) < 2 {
+			return []string{getUsageMessage(lang, 2, tr(lang, "mod.usage.revoke"))}, nil
+		}
+		return app.handleVoucherBatchRevoke(lang, msgParts[1])
+
+	case "активации":
+		if len(msgParts) < 2 {
+			return []string{getUsageMessage(lang, 2, tr(lang, "mod.usage.redemptions"))}, nil
+		}
+		return app.handleVoucherBatchStats(lang, msgParts[1])
+
+	case "подозрительные":
+		return app.handleFlaggedUsersList(lang)
+
+	case "фрод":
+		if len(msgParts) < 2 {
+			return []string{getUsageMessage(lang, 2, tr(lang, "mod.usage.fraud"))}, nil
+		}
+		return app.handleFraudScoreView(lang, msgParts[1])
+
+	case "проверено":
+		if len(msgParts) < 2 {
+			return []string{getUsageMessage(lang, 2, tr(lang, "mod.usage.cleared"))}, nil
+		}
+		return app.handleFraudClear(lang, msgParts[1])
+
+	case "заморозить", "бан", "блок":
+		if len(msgParts) < 4 {
+			return []string{getUsageMessage(lang, 4, tr(lang, "mod.usage.restrict", command, command))}, nil
+		}
+		kinds := map[string]int{
+			"заморозить": restrictionFreeze,
+			"бан":        restrictionBan,
+			"блок":       restrictionBlock,
+		}
+		return app.handleRestrictUser(lang, kinds[command], msgParts[1:])
+
+	case "разбан":
+		if len(msgParts) < 2 {
+			return []string{getUsageMessage(lang, 2, tr(lang, "mod.usage.unrestrict"))}, nil
+		}
+		return app.handleUnrestrictUser(lang, msgParts[1])
+
+	case "ограничения":
+		return app.handleRestrictionsList(lang)
+
+	case "очередь":
+		return app.handleOutboundQueueStats(lang)
+
+	case "ставка":
+		return app.handleRewardRatePreview(lang)
+
+	case "бюджет":
+		return app.handleBudgetRequest(lang)
+
+	case "faq", "чаво":
+		return app.handleFAQRequest(lang, messageText)
+
+	case "тикеты":
+		return app.handleSupportTicketsList(lang)
+
+	case "тикет":
+		if len(msgParts) < 2 {
+			return []string{getUsageMessage(lang, 2, tr(lang, "mod.usage.ticket"))}, nil
 		}
-		return app.handleVoucherDelete(msgParts[1])
+		return app.handleSupportTicketHistory(lang, msgParts[1])
+
+	case "закрыть":
+		if len(msgParts) < 2 {
+			return []string{getUsageMessage(lang, 2, tr(lang, "mod.usage.ticket_close"))}, nil
+		}
+		return app.handleSupportTicketClose(lang, msgParts[1], telegramUserID)
+
+	case "повторить":
+		if len(msgParts) < 2 {
+			return []string{getUsageMessage(lang, 2, tr(lang, "mod.usage.retry"))}, nil
+		}
+		return app.handleOutboundRetry(lang, msgParts[1])
 	}
 }
 
-func (app *solution) handleVoucherDelete(voucherCode string) ([]string, error) {
-	if err := app.DB.deleteGameVoucher(voucherCode); err != nil {
-		return nil, err
-	}
+// returns "the request must contain N parts" message with command usage
+func getUsageMessage(lang string, partsCount int, usage string) string {
+	return tr(lang, "mod.usage.parts_"+strconv.Itoa(partsCount), usage)
+}
 
-	return []string{"OK! ваучер был удален"}, nil
+func (app *solution) deleteVoucher(voucherCode string) error {
+	voucherCode = strings.ToUpper(voucherCode)
+	err := app.DB.deleteGameVoucher(voucherCode)
+	if errors.Is(err, errVoucherNotFound) {
+		// not a single-use voucher, try to find it in batches
+		return app.DB.revokeBatchVoucher(voucherCode)
+	}
+	return err
 }
 
-func (app *solution) handleCreateVoucherRequest(amountRaw string) ([]string, error) {
-	amount, err := strconv.ParseFloat(amountRaw, 64)
+func (app *solution) handleVoucherDelete(lang, voucherCode string) ([]string, error) {
+	err := app.deleteVoucher(voucherCode)
+	if errors.Is(err, errVoucherNotFound) {
+		return []string{tr(lang, "mod.voucher_not_found")}, nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("parse amount: %w", err)
+		return nil, err
 	}
 
+	return []string{tr(lang, "mod.voucher_deleted")}, nil
+}
+
+// returns voucher code
+func (app *solution) createVoucher(amount float64) (string, error) {
 	if amount <= 0 {
-		return nil, errors.New("invalid voucher amount")
+		return "", errors.New("invalid voucher amount")
 	}
 
 	if amount > maxGameVoucherAmount {
-		return nil, fmt.Errorf("max voucher amount is %v", maxGameVoucherAmount)
+		return "", fmt.Errorf("max voucher amount is %v", maxGameVoucherAmount)
 	}
 
-	voucher := app.genGameVoucher()
-	if err := app.DB.saveGameVoucher(voucher, amount); err != nil {
-		return nil, err
+	voucher, err := app.genGameVoucher()
+	if err != nil {
+		return "", err
 	}
-
-	return []string{
-		"Ваучер успешно создан:\n\n" + voucher +
-			"\n\nСумма: " + strconv.FormatFloat(amount, 'f', 4, 64),
-	}, nil
+	return voucher, app.DB.saveGameVoucher(voucher, amount)
 }
 
-func (app *solution) handleUsersOnlineRequest(fromTelegram bool) ([]string, error) {
-	return app.getUsersOnline(fromTelegram)
-}
+func (app *solution) handleCreateVoucherRequest(lang, amountRaw string) ([]string, error) {
+	amount, err := strconv.ParseFloat(amountRaw, 64)
+	if err != nil {
+		return nil, fmt.Errorf("parse amount: %w", err)
+	}
 
-func (app *solution) getChannelOnline() ([]utopiago.ChannelContactData, error) {
-	contacts, err := app.Config.UtopiaCfg.GetChannelContacts(app.Config.ChannelID)
+	voucher, err := app.createVoucher(amount)
 	if err != nil {
 		return nil, err
 	}
 
-	if app.Config.HealthCheckStrictMode && len(contacts) == 0 {
-		return nil, doBotReboot()
-	}
+	return []string{
+		tr(lang, "mod.voucher_created", voucher, strconv.FormatFloat(amount, 'f', 4, 64)),
+	}, nil
+}
 
-	return contacts, nil
+func (app *solution) handleUsersOnlineRequest(lang string, fromTelegram bool) ([]string, error) {
+	return app.getUsersOnline(lang, fromTelegram)
 }
 
 // returns map[nick]data, error
@@ -418,17 +575,16 @@ func (app *solution) getChannelOnlineMap(onlineData []utopiago.ChannelContactDat
 	return result
 }
 
-func (app *solution) getUsersOnline(fromTelegram bool) ([]string, error) {
+func (app *solution) getUsersOnline(lang string, fromTelegram bool) ([]string, error) {
 	contacts, err := app.Config.UtopiaCfg.GetContacts("")
 	if err != nil {
 		return []string{}, err
 	}
 
-	channelOnline, err := app.getChannelOnline()
+	channels, err := app.getChannelsOnline()
 	if err != nil {
 		return []string{}, err
 	}
-	channelOnlineMap := app.getChannelOnlineMap(channelOnline)
 
 	var msgParts []string = make([]string, 0)
 	var msgPart string
@@ -447,8 +603,7 @@ func (app *solution) getUsersOnline(fromTelegram bool) ([]string, error) {
 					}
 
 				} else {
-					_, isUserInChannel := channelOnlineMap[contact.Nick]
-					if isUserInChannel {
+					if channels.isEligible(contact.Nick) {
 						if fromTelegram {
 							onlineTag = "🟩"
 						} else {
@@ -481,12 +636,12 @@ func (app *solution) getUsersOnline(fromTelegram bool) ([]string, error) {
 		}
 
 		if len(msgParts) > 0 {
-			msgParts[0] = "Пользователи онлайн: " + strconv.Itoa(usersOnline) + "\n" + msgParts[0]
+			msgParts[0] = tr(lang, "mod.users_online", usersOnline) + "\n" + msgParts[0]
 		}
 	}
 
 	if usersOnline == 0 {
-		return []string{"Никого нет онлайн"}, nil
+		return []string{tr(lang, "mod.nobody_online")}, nil
 	}
 
 	return msgParts, nil
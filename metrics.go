@@ -0,0 +1,171 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/logger"
+)
+
+const (
+	metricTypeCounter = "counter"
+	metricTypeGauge   = "gauge"
+)
+
+// metric in prometheus text format
+type metric struct {
+	sync.Mutex
+	Name   string
+	Help   string
+	Type   string
+	Label  string             // optional label name
+	Values map[string]float64 // label value -> metric value
+}
+
+type metricsRegistry struct {
+	Metrics []*metric
+}
+
+type botMetricsData struct {
+	metricsRegistry
+
+	Contacts           *metric
+	ContactsOnline     *metric
+	ChannelOnline      *metric
+	ContactsInChannel  *metric
+	PointsAccrued      *metric
+	PointsAccruedTick  *metric
+	VoucherRedemptions *metric
+	Withdrawals        *metric
+	PointsWithdrawn    *metric
+	MessagesSent       *metric
+	UtopiaAPIErrors    *metric
+	WsReconnects       *metric
+	AutoReboots        *metric
+	WsEventsQueue      *metric
+	WsHandlerTimeouts  *metric
+	NLUAnswers         *metric
+	PointsIssuedToday  *metric
+}
+
+var botMetrics = newBotMetrics()
+
+// finds API method & status in utopia client log message
+var utopiaLogErrorRegexp = regexp.MustCompile(`-> ([A-Za-z0-9_]+)\. elapsed [^:]*: error`)
+
+func (r *metricsRegistry) newMetric(metricType, name, help, label string) *metric {
+	m := &metric{
+		Name:   name,
+		Help:   help,
+		Type:   metricType,
+		Label:  label,
+		Values: map[string]float64{},
+	}
+	r.Metrics = append(r.Metrics, m)
+	return m
+}
+
+func newBotMetrics() *botMetricsData {
+	m := &botMetricsData{}
+	m.Contacts = m.newMetric(metricTypeGauge, "talk2earn_contacts", "Bot contacts count", "")
+	m.ContactsOnline = m.newMetric(metricTypeGauge, "talk2earn_contacts_online", "Contacts online", "")
+	m.ChannelOnline = m.newMetric(metricTypeGauge, "talk2earn_channel_online", "Users online in the channel", "")
+	m.ContactsInChannel = m.newMetric(metricTypeGauge, "talk2earn_contacts_in_channel", "Contacts online in the channel", "")
+	m.PointsAccrued = m.newMetric(metricTypeCounter, "talk2earn_points_accrued_total", "Points accrued for online", "")
+	m.PointsAccruedTick = m.newMetric(metricTypeGauge, "talk2earn_points_accrued_last_tick", "Points accrued in the last contacts check", "")
+	m.VoucherRedemptions = m.newMetric(metricTypeCounter, "talk2earn_voucher_redemptions_total", "Activated vouchers", "")
+	m.Withdrawals = m.newMetric(metricTypeCounter, "talk2earn_withdrawals_total", "Points withdrawals", "")
+	m.PointsWithdrawn = m.newMetric(metricTypeCounter, "talk2earn_points_withdrawn_total", "Withdrawn points", "")
+	m.MessagesSent = m.newMetric(metricTypeCounter, "talk2earn_messages_sent_total", "Messages sent to users", "")
+	m.UtopiaAPIErrors = m.newMetric(metricTypeCounter, "talk2earn_utopia_api_errors_total", "Utopia API errors", "method")
+	m.WsReconnects = m.newMetric(metricTypeCounter, "talk2earn_ws_reconnects_total", "Utopia websocket reconnects", "")
+	m.AutoReboots = m.newMetric(metricTypeCounter, "talk2earn_auto_reboots_total", "Service reboots", "service")
+	m.WsEventsQueue = m.newMetric(metricTypeGauge, "talk2earn_ws_events_queue", "Websocket events waiting or in handling", "")
+	m.WsHandlerTimeouts = m.newMetric(metricTypeCounter, "talk2earn_ws_handler_timeouts_total", "Websocket event handlers timed out", "")
+	m.NLUAnswers = m.newMetric(metricTypeCounter, "talk2earn_nlu_answers_total", "Unknown messages by answer source: faq, nlu backend or none", "backend")
+	m.PointsIssuedToday = m.newMetric(metricTypeGauge, "talk2earn_points_issued_today", "Points accrued and activated in vouchers today, set when budget is enabled", "")
+	return m
+}
+
+func (m *metric) add(value float64) {
+	m.addWithLabel("", value)
+}
+
+func (m *metric) inc() {
+	m.addWithLabel("", 1)
+}
+
+func (m *metric) addWithLabel(labelValue string, value float64) {
+	m.Lock()
+	defer m.Unlock()
+	m.Values[labelValue] += value
+}
+
+func (m *metric) set(value float64) {
+	m.Lock()
+	defer m.Unlock()
+	m.Values[""] = value
+}
+
+func (m *metric) get() float64 {
+	m.Lock()
+	defer m.Unlock()
+	return m.Values[""]
+}
+
+func (m *metric) write(sb *strings.Builder) {
+	m.Lock()
+	defer m.Unlock()
+
+	sb.WriteString("# HELP " + m.Name + " " + m.Help + "\n")
+	sb.WriteString("# TYPE " + m.Name + " " + m.Type + "\n")
+
+	if m.Label == "" {
+		sb.WriteString(m.Name + " " + strconv.FormatFloat(m.Values[""], 'f', -1, 64) + "\n")
+		return
+	}
+
+	labelValues := make([]string, 0, len(m.Values))
+	for labelValue := range m.Values {
+		labelValues = append(labelValues, labelValue)
+	}
+	sort.Strings(labelValues)
+
+	for _, labelValue := range labelValues {
+		sb.WriteString(m.Name + "{" + m.Label + "=" + strconv.Quote(labelValue) + "} " +
+			strconv.FormatFloat(m.Values[labelValue], 'f', -1, 64) + "\n")
+	}
+}
+
+func (r *metricsRegistry) export() string {
+	sb := strings.Builder{}
+	for _, m := range r.Metrics {
+		m.write(&sb)
+	}
+	return sb.String()
+}
+
+func (d *botMetricsData) setContactsData(data *getContactsResult) {
+	d.Contacts.set(float64(data.Contacts))
+	d.ContactsOnline.set(float64(data.ContactsOnline))
+	d.ChannelOnline.set(float64(data.ChannelOnline))
+	d.ContactsInChannel.set(float64(data.ContactsInChannel))
+}
+
+func (d *botMetricsData) handleUtopiaLog(logMessage string) {
+	match := utopiaLogErrorRegexp.FindStringSubmatch(logMessage)
+	if match != nil {
+		d.UtopiaAPIErrors.addWithLabel(match[1], 1)
+	}
+}
+
+func (app *solution) handleMetricsRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if _, err := w.Write([]byte(botMetrics.export())); err != nil {
+		logger.Error(err)
+	}
+}
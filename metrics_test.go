@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMetricsExport(t *testing.T) {
+	r := metricsRegistry{}
+	counter := r.newMetric(metricTypeCounter, "test_total", "Test counter", "")
+	labeled := r.newMetric(metricTypeCounter, "test_errors_total", "Test errors", "method")
+
+	counter.inc()
+	counter.add(2)
+	labeled.addWithLabel("getContacts", 1)
+
+	expected := "# HELP test_total Test counter\n" +
+		"# TYPE test_total counter\n" +
+		"test_total 3\n" +
+		"# HELP test_errors_total Test errors\n" +
+		"# TYPE test_errors_total counter\n" +
+		"test_errors_total{method=\"getContacts\"} 1\n"
+	if r.export() != expected {
+		t.Fatalf("unexpected export:\n%s", r.export())
+	}
+}
+
+func TestUtopiaLogErrors(t *testing.T) {
+	m := newBotMetrics()
+	m.handleUtopiaLog("1654200000000: POST http://127.0.0.1:22659/api/1.0/ -> getContacts. " +
+		"elapsed 10.5s: error: failed to send request: context deadline exceeded\nrequest: ...")
+	m.handleUtopiaLog("1654200000000: POST http://127.0.0.1:22659/api/1.0/ -> getContacts. " +
+		"elapsed 15ms: success\nrequest: ...")
+
+	if !strings.Contains(m.export(), `talk2earn_utopia_api_errors_total{method="getContacts"} 1`) {
+		t.Fatal("api error should be counted once")
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/google/logger"
+)
+
+// public endpoints for monitoring systems, no token required
+func (app *solution) runMonitoringServer() error {
+	if !app.Config.Monitoring.Enabled {
+		return nil
+	}
+
+	logger.Info("run monitoring server..")
+
+	if app.Config.Monitoring.Listen == "" {
+		// empty address means all interfaces
+		app.Config.Monitoring.Listen = defaultMonitoringListen
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", app.handleMetricsRequest)
+	mux.HandleFunc("/healthz", app.handleHealthzRequest)
+	mux.HandleFunc("/readyz", app.handleReadyzRequest)
+
+	app.MonitoringServer = &http.Server{
+		Addr:    app.Config.Monitoring.Listen,
+		Handler: mux,
+	}
+	go func() {
+		if err := app.MonitoringServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error("monitoring server stopped: " + err.Error())
+		}
+	}()
+	return nil
+}
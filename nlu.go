@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	dialogflow "cloud.google.com/go/dialogflow/apiv2"
+	"github.com/google/logger"
+	dialogflowpb "google.golang.org/genproto/googleapis/cloud/dialogflow/v2"
+)
+
+const (
+	nluBackendLocal      = "local"
+	nluBackendDialogflow = "dialogflow"
+)
+
+// natural language understanding backend.
+// empty answer means the intent is not recognized
+type nluBackend interface {
+	Name() string
+	DetectIntent(ctx context.Context, sessionID, lang, text string) (nluResult, error)
+	Close() error
+}
+
+type nluResult struct {
+	Intent string
+	Answer string
+	Score  float64 // 0..1
+}
+
+// asks backends in order until one of them answers
+type nluChain []nluBackend
+
+func newNLU(ctx context.Context, cfg nluConfig) (nluChain, error) {
+	chain := nluChain{}
+	for _, name := range cfg.Backends {
+		switch name {
+		default:
+			chain.Close()
+			return nil, errors.New("unknown nlu backend: " + name)
+		case nluBackendLocal:
+			chain = append(chain, newLocalNLU(cfg.Intents, cfg.MinScore))
+		case nluBackendDialogflow:
+			backend, err := newDialogflowNLU(ctx, cfg.DialogflowProjectID, cfg.DialogflowLangcode)
+			if err != nil {
+				chain.Close()
+				return nil, err
+			}
+			chain = append(chain, backend)
+		}
+	}
+	return chain, nil
+}
+
+func (chain nluChain) DetectIntent(ctx context.Context, sessionID, lang, text string) (nluResult, string, error) {
+	for _, backend := range chain {
+		result, err := backend.DetectIntent(ctx, sessionID, lang, text)
+		if err != nil {
+			return nluResult{}, backend.Name(), err
+		}
+		if result.Answer != "" {
+			return result, backend.Name(), nil
+		}
+	}
+	return nluResult{}, "", nil
+}
+
+func (chain nluChain) Close() {
+	for _, backend := range chain {
+		if err := backend.Close(); err != nil {
+			logger.Error(err)
+		}
+	}
+}
+
+// dialogflow session ID must be up to 36 symbols
+func getNLUSessionID(pubkey string) string {
+	hash := sha256.Sum256([]byte(pubkey))
+	return hex.EncodeToString(hash[:16])
+}
+
+type dialogflowNLU struct {
+	Client       *dialogflow.SessionsClient
+	ProjectID    string
+	LanguageCode string // for the default language, example: ru-RU
+}
+
+func newDialogflowNLU(ctx context.Context, projectID, languageCode string) (*dialogflowNLU, error) {
+	if projectID == "" {
+		return nil, errors.New("dialogflow project ID is not set")
+	}
+
+	client, err := dialogflow.NewSessionsClient(ctx)
+	if err != nil {
+		return nil, errors.New("failed to create dialogflow client: " + err.Error())
+	}
+	return &dialogflowNLU{
+		Client:       client,
+		ProjectID:    projectID,
+		LanguageCode: languageCode,
+	}, nil
+}
+
+func (d *dialogflowNLU) Name() string {
+	return nluBackendDialogflow
+}
+
+func (d *dialogflowNLU) getLanguageCode(lang string) string {
+	if lang == catalog.DefaultLang && d.LanguageCode != "" {
+		return d.LanguageCode
+	}
+	return lang
+}
+
+func (d *dialogflowNLU) DetectIntent(ctx context.Context, sessionID, lang, text string) (nluResult, error) {
+	sessionPath := fmt.Sprintf("projects/%s/agent/sessions/%s", d.ProjectID, sessionID)
+	textInput := dialogflowpb.TextInput{Text: text, LanguageCode: d.getLanguageCode(lang)}
+	queryTextInput := dialogflowpb.QueryInput_Text{Text: &textInput}
+	queryInput := dialogflowpb.QueryInput{Input: &queryTextInput}
+	request := dialogflowpb.DetectIntentRequest{Session: sessionPath, QueryInput: &queryInput}
+
+	response, err := d.Client.DetectIntent(ctx, &request)
+	if err != nil {
+		return nluResult{}, err
+	}
+
+	queryResult := response.GetQueryResult()
+	return nluResult{
+		Intent: queryResult.GetIntent().GetDisplayName(),
+		Answer: queryResult.GetFulfillmentText(),
+		Score:  float64(queryResult.GetIntentDetectionConfidence()),
+	}, nil
+}
+
+func (d *dialogflowNLU) Close() error {
+	return d.Client.Close()
+}
+
+// offline intent matcher by keywords & example phrases
+type localNLU struct {
+	Intents  []nluIntent
+	MinScore float64
+}
+
+func newLocalNLU(intents []nluIntent, minScore float64) *localNLU {
+	if minScore <= 0 {
+		minScore = defaultNLUMinScore
+	}
+	return &localNLU{
+		Intents:  intents,
+		MinScore: minScore,
+	}
+}
+
+func (l *localNLU) Name() string {
+	return nluBackendLocal
+}
+
+func (l *localNLU) Close() error {
+	return nil
+}
+
+func (l *localNLU) DetectIntent(ctx context.Context, sessionID, lang, text string) (nluResult, error) {
+	best := nluResult{}
+	words := splitNLUWords(text)
+	for _, intent := range l.Intents {
+		score := intent.getScore(words)
+		if score < l.MinScore || score <= best.Score {
+			continue
+		}
+
+		best = nluResult{
+			Intent: intent.Name,
+			Answer: intent.getAnswer(lang),
+			Score:  score,
+		}
+	}
+	return best, nil
+}
+
+func splitNLUWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// returns 0..1, 1 for equal strings
+func getStringsSimilarity(a, b string) float64 {
+	maxLength := len([]rune(a))
+	if l := len([]rune(b)); l > maxLength {
+		maxLength = l
+	}
+	if maxLength == 0 {
+		return 1
+	}
+	return 1 - float64(getLevenshteinDistance(a, b))/float64(maxLength)
+}
+
+func isNLUKeywordValid(keyword string) bool {
+	return utf8.RuneCountInString(keyword) >= nluMinKeywordLength
+}
+
+// keyword matches word forms: "вывод" -> "выводить"
+func getKeywordScore(keyword string, words []string) float64 {
+	best := 0.0
+	for _, word := range words {
+		if strings.HasPrefix(word, keyword) {
+			return 1
+		}
+		if similarity := getStringsSimilarity(keyword, word); similarity > best {
+			best = similarity
+		}
+	}
+	return best
+}
+
+func (intent nluIntent) getScore(words []string) float64 {
+	best := 0.0
+	for _, keyword := range intent.Keywords {
+		if !isNLUKeywordValid(keyword) {
+			continue
+		}
+		if score := getKeywordScore(strings.ToLower(keyword), words); score > best {
+			best = score
+		}
+	}
+
+	text := strings.Join(words, " ")
+	for _, phrase := range intent.Phrases {
+		score := getStringsSimilarity(strings.Join(splitNLUWords(phrase), " "), text)
+		if score > best {
+			best = score
+		}
+	}
+	return best
+}
+
+func (intent nluIntent) getAnswer(lang string) string {
+	if answer, isFound := intent.Answers[lang]; isFound {
+		return answer
+	}
+	return intent.Answers[catalog.DefaultLang]
+}
+
+func (app *solution) initNLU() error {
+	cfg := app.Config.NLU
+	if len(cfg.Backends) == 0 && app.Config.DialogflowEnabled {
+		// old config
+		cfg.Backends = []string{nluBackendDialogflow}
+	}
+	if cfg.DialogflowProjectID == "" {
+		cfg.DialogflowProjectID = app.Config.DialogflowProjectID
+	}
+	if cfg.DialogflowLangcode == "" {
+		cfg.DialogflowLangcode = app.Config.DialogflowLandcode
+	}
+	if len(cfg.Backends) == 0 {
+		return nil
+	}
+
+	logger.Info("setup nlu: " + strings.Join(cfg.Backends, ", ") + "..")
+	var err error
+	app.NLU, err = newNLU(app.Ctx, cfg)
+	return err
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLocalNLU(t *testing.T) {
+	nlu := newLocalNLU([]nluIntent{
+		{
+			Name:     "withdraw",
+			Keywords: []string{"вывод", "withdraw"},
+			Answers:  map[string]string{"ru": "вывод от 150 баллов", "en": "withdraw from 150 points"},
+		},
+		{
+			Name:     "start",
+			Keywords: []string{"с"}, // too short, ignored
+			Answers:  map[string]string{"ru": "начните с команды"},
+		},
+		{
+			Name:    "mining",
+			Phrases: []string{"это майнинг?"},
+			Answers: map[string]string{"ru": "нет, это не майнинг"},
+		},
+	}, 0)
+
+	cases := []struct {
+		Text   string
+		Lang   string
+		Intent string
+		Answer string
+	}{
+		{"как выводить баллы", "ru", "withdraw", "вывод от 150 баллов"},
+		{"how to withdarw points?", "en", "withdraw", "withdraw from 150 points"},
+		{"Это майнинг", "en", "mining", "нет, это не майнинг"}, // default language answer
+		{"сколько стоит", "ru", "", ""},
+	}
+	for _, c := range cases {
+		r, err := nlu.DetectIntent(context.Background(), "", c.Lang, c.Text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Intent != c.Intent || r.Answer != c.Answer {
+			t.Errorf("%q: expected %q intent, got %+v", c.Text, c.Intent, r)
+		}
+	}
+
+	if id := getNLUSessionID("pubkey"); len(id) > 36 || id == getNLUSessionID("pubkey2") {
+		t.Fatalf("invalid session ID: %q", id)
+	}
+}
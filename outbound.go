@@ -0,0 +1,209 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	utopiago "github.com/Sagleft/utopialib-go"
+	"github.com/google/logger"
+)
+
+const (
+	messagePriorityLow    = 0 // broadcasts
+	messagePriorityNormal = 1 // replies
+	messagePriorityHigh   = 2 // moderators replies, voucher confirmations
+
+	outboundStatusPending = 0
+	outboundStatusDead    = 1
+)
+
+type outboundMessage struct {
+	ID            int64
+	Pubkey        string
+	Text          string
+	Priority      int
+	DedupKey      string // empty - no dedup
+	Attempts      int
+	NextAttemptAt int64 // unix timestamp
+	LastError     string
+	CreatedAt     int64 // unix timestamp
+}
+
+// DB methods used by the queue worker
+type outboundStore interface {
+	getDueOutboundMessages(limit int) ([]outboundMessage, error)
+	deleteOutboundMessage(id int64) error
+	saveOutboundMessageFailure(m outboundMessage, isDead bool) error
+}
+
+type outboundQueue struct {
+	Store   outboundStore
+	Deliver func(pubkey, text string) error
+}
+
+// message key is set by the caller, e.g. welcome message number.
+// the same key for the same user is not queued twice while pending
+func getOutboundDedupKey(pubkey, messageKey string) string {
+	if messageKey == "" {
+		return ""
+	}
+	hash := sha256.Sum256([]byte(pubkey + "\n" + messageKey))
+	return hex.EncodeToString(hash[:])
+}
+
+func (app *solution) sendMessageWithPriority(pubkey string, text string, priority int) error {
+	return app.sendMessageOnce(pubkey, text, priority, "")
+}
+
+// skips the message when a message with the same key is pending, empty key disables dedup
+func (app *solution) sendMessageOnce(pubkey, text string, priority int, messageKey string) error {
+	isQueued, err := app.DB.enqueueOutboundMessage(outboundMessage{
+		Pubkey:    pubkey,
+		Text:      text,
+		Priority:  priority,
+		DedupKey:  getOutboundDedupKey(pubkey, messageKey),
+		CreatedAt: time.Now().Unix(),
+	})
+	if err != nil {
+		return err
+	}
+	if !isQueued {
+		logger.Info("skip duplicate message " + messageKey + " to " + pubkey)
+		return nil
+	}
+
+	app.wakeOutboundQueue()
+	return nil
+}
+
+func (app *solution) wakeOutboundQueue() {
+	select {
+	case app.OutboundWakeup <- struct{}{}:
+	default:
+	}
+}
+
+func (app *solution) runOutboundQueue() error {
+	app.Outbound = &outboundQueue{Store: app.DB, Deliver: app.deliverMessage}
+	app.OutboundWakeup = make(chan struct{}, 1)
+	app.OutboundDone = make(chan struct{})
+	go func() {
+		defer close(app.OutboundDone)
+		for !app.isShuttingDown() {
+			if app.handleOutboundQueue() == 0 {
+				select {
+				case <-app.OutboundWakeup:
+				case <-time.After(outboundQueuePollInterval):
+				case <-app.ShutdownCh:
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// returns processed messages count
+func (app *solution) handleOutboundQueue() int {
+	processed, err := app.Outbound.handle()
+	if err != nil {
+		app.onUtopiaError(err)
+		return 0 // wait for reconnect
+	}
+	return processed
+}
+
+// returns processed messages count and connection error
+func (q *outboundQueue) handle() (int, error) {
+	messages, err := q.Store.getDueOutboundMessages(outboundQueueBatchSize)
+	if err != nil {
+		logger.Error(err)
+		return 0, nil
+	}
+
+	for _, m := range messages {
+		err := q.Deliver(m.Pubkey, m.Text)
+		if err == nil {
+			if err := q.Store.deleteOutboundMessage(m.ID); err != nil {
+				logger.Error(err)
+			}
+			continue
+		}
+
+		q.onMessageFailed(m, err, time.Now())
+		if utopiago.CheckErrorConnBroken(err) {
+			// no need to try other messages now
+			return 0, err
+		}
+	}
+	return len(messages), nil
+}
+
+// returns the message with the next attempt scheduled and true when the message is dead
+func getOutboundRetry(m outboundMessage, sendErr error, now time.Time) (outboundMessage, bool) {
+	m.Attempts++
+	m.LastError = LimitStringLength(sendErr.Error(), outboundErrorMaxLength)
+	m.NextAttemptAt = now.Add(getReconnectDelay(
+		m.Attempts-1, outboundRetryMinDelay, outboundRetryMaxDelay,
+	)).Unix()
+	return m, m.Attempts >= outboundMaxAttempts
+}
+
+func (q *outboundQueue) onMessageFailed(m outboundMessage, sendErr error, now time.Time) {
+	m, isDead := getOutboundRetry(m, sendErr, now)
+	if isDead {
+		logger.Error("message " + strconv.FormatInt(m.ID, 10) + " to " + m.Pubkey +
+			" moved to dead letters: " + m.LastError)
+	}
+	if err := q.Store.saveOutboundMessageFailure(m, isDead); err != nil {
+		logger.Error(err)
+	}
+}
+
+func (app *solution) handleOutboundQueueStats(lang string) ([]string, error) {
+	pending, dead, err := app.DB.getOutboundQueueStats()
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := app.DB.getDeadOutboundMessages(outboundDeadListLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := tr(lang, "mod.queue_stats", pending, dead)
+	for _, m := range messages {
+		text := LimitStringLength(m.Text, outboundDeadTextPreviewLength)
+		msg += tr(lang, "mod.queue_dead_item", m.ID, m.Pubkey, m.Attempts, m.LastError,
+			strings.ReplaceAll(text, "\n", " "))
+	}
+	if dead > 0 {
+		msg += tr(lang, "mod.queue_retry_hint")
+	}
+	return []string{msg}, nil
+}
+
+func (app *solution) handleOutboundRetry(lang, rawID string) ([]string, error) {
+	var id int64
+	if rawID != "все" {
+		var err error
+		id, err = strconv.ParseInt(strings.TrimPrefix(rawID, "#"), 10, 64)
+		if err != nil || id <= 0 {
+			return nil, errors.New("invalid message number: " + rawID)
+		}
+	}
+
+	count, err := app.DB.retryDeadOutboundMessages(id)
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return []string{tr(lang, "mod.retry_not_found")}, nil
+	}
+
+	app.wakeOutboundQueue()
+	return []string{tr(lang, "mod.retry_done", count)}, nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// keeps messages in memory, due messages are ordered like in DB
+type fakeOutboundStore struct {
+	Messages []outboundMessage
+	Dead     []outboundMessage
+}
+
+func (s *fakeOutboundStore) getDueOutboundMessages(limit int) ([]outboundMessage, error) {
+	due := []outboundMessage{}
+	for _, m := range s.Messages {
+		if m.NextAttemptAt <= time.Now().Unix() {
+			due = append(due, m)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		if due[i].Priority != due[j].Priority {
+			return due[i].Priority > due[j].Priority
+		}
+		return due[i].ID < due[j].ID
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	return due, nil
+}
+
+func (s *fakeOutboundStore) deleteOutboundMessage(id int64) error {
+	for i, m := range s.Messages {
+		if m.ID == id {
+			s.Messages = append(s.Messages[:i], s.Messages[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (s *fakeOutboundStore) saveOutboundMessageFailure(m outboundMessage, isDead bool) error {
+	if err := s.deleteOutboundMessage(m.ID); err != nil {
+		return err
+	}
+	if isDead {
+		s.Dead = append(s.Dead, m)
+	} else {
+		s.Messages = append(s.Messages, m)
+	}
+	return nil
+}
+
+func TestOutboundQueuePriority(t *testing.T) {
+	store := &fakeOutboundStore{Messages: []outboundMessage{
+		{ID: 1, Text: "broadcast", Priority: messagePriorityLow},
+		{ID: 2, Text: "reply", Priority: messagePriorityNormal},
+		{ID: 3, Text: "voucher", Priority: messagePriorityHigh},
+		{ID: 4, Text: "reply 2", Priority: messagePriorityNormal},
+	}}
+	sent := []string{}
+	queue := outboundQueue{Store: store, Deliver: func(pubkey, text string) error {
+		sent = append(sent, text)
+		return nil
+	}}
+
+	processed, err := queue.handle()
+	if err != nil || processed != 4 {
+		t.Fatalf("expected 4 processed messages, got %v, %v", processed, err)
+	}
+	if !reflect.DeepEqual(sent, []string{"voucher", "reply", "reply 2", "broadcast"}) {
+		t.Fatalf("unexpected order: %v", sent)
+	}
+	if len(store.Messages) != 0 {
+		t.Fatalf("sent messages must be deleted, got %v", store.Messages)
+	}
+}
+
+func TestOutboundQueueRetry(t *testing.T) {
+	store := &fakeOutboundStore{Messages: []outboundMessage{{ID: 1, Text: "reply"}}}
+	queue := outboundQueue{Store: store, Deliver: func(pubkey, text string) error {
+		return errors.New("contact is offline")
+	}}
+
+	startedAt := time.Now()
+	if _, err := queue.handle(); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.Messages) != 1 || len(store.Dead) != 0 {
+		t.Fatal("failed message must stay in queue")
+	}
+
+	m := store.Messages[0]
+	if m.Attempts != 1 || m.LastError != "contact is offline" {
+		t.Fatalf("unexpected failed message: %+v", m)
+	}
+	if m.NextAttemptAt < startedAt.Add(outboundRetryMinDelay/2).Unix() {
+		t.Fatal("next attempt must be delayed")
+	}
+
+	// not due yet
+	if processed, _ := queue.handle(); processed != 0 {
+		t.Fatalf("expected no due messages, got %v", processed)
+	}
+}
+
+func TestOutboundRetryBackoff(t *testing.T) {
+	now := time.Now()
+	sendErr := errors.New("timeout")
+	m := outboundMessage{ID: 1}
+
+	var total time.Duration
+	for attempt := 1; attempt < outboundMaxAttempts; attempt++ {
+		var isDead bool
+		m, isDead = getOutboundRetry(m, sendErr, now)
+		if isDead {
+			t.Fatalf("message is dead after %v attempts", attempt)
+		}
+
+		delay := time.Unix(m.NextAttemptAt, 0).Sub(now)
+		if delay > outboundRetryMaxDelay+time.Second {
+			t.Fatalf("attempt %v: delay %v is more than max", attempt, delay)
+		}
+		total += delay
+	}
+
+	if _, isDead := getOutboundRetry(m, sendErr, now); !isDead {
+		t.Fatalf("message must be dead after %v attempts", outboundMaxAttempts)
+	}
+	if total < 5*time.Hour {
+		t.Fatalf("retries must last for hours, got %v", total)
+	}
+}
+
+func TestOutboundQueueDeadLetter(t *testing.T) {
+	store := &fakeOutboundStore{Messages: []outboundMessage{
+		{ID: 1, Text: "reply", Attempts: outboundMaxAttempts - 1},
+	}}
+	queue := outboundQueue{Store: store, Deliver: func(pubkey, text string) error {
+		return errors.New("contact not found")
+	}}
+
+	if _, err := queue.handle(); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.Messages) != 0 || len(store.Dead) != 1 {
+		t.Fatalf("expected dead message, got queue %v, dead %v", store.Messages, store.Dead)
+	}
+	if store.Dead[0].Attempts != outboundMaxAttempts {
+		t.Fatalf("unexpected attempts: %v", store.Dead[0].Attempts)
+	}
+}
+
+func TestOutboundDedupKey(t *testing.T) {
+	if key := getOutboundDedupKey("PK1", ""); key != "" {
+		t.Fatalf("messages without key must not be deduplicated, got %q", key)
+	}
+
+	key := getOutboundDedupKey("PK1", "welcome:0")
+	if len(key) != 64 {
+		t.Fatalf("expected sha256 hex key, got %q", key)
+	}
+	if key != getOutboundDedupKey("PK1", "welcome:0") {
+		t.Fatal("the same message key must give the same dedup key")
+	}
+	if key == getOutboundDedupKey("PK1", "welcome:1") || key == getOutboundDedupKey("PK2", "welcome:0") {
+		t.Fatal("dedup key must depend on the user and the message key")
+	}
+}
@@ -1,24 +1,30 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"strconv"
 	"time"
 
 	"github.com/google/logger"
 )
 
-func (app *solution) viewUserBalance(userPubkey string) (string, error) {
+var (
+	errUserNotFound    = errors.New("user not found")
+	errNotEnoughPoints = errors.New("not enough points")
+)
+
+func (app *solution) viewUserBalance(lang, userPubkey string) (string, error) {
 	if userPubkey == "" {
-		return "проверь правильность запроса, я не смог найти в нем публичный ключ", nil
+		return tr(lang, "mod.pubkey_not_found"), nil
 	}
 
 	if len(userPubkey) != 64 {
-		return "Неверная длина публичного ключа юзера", nil
+		return tr(lang, "mod.pubkey_invalid_length"), nil
 	}
 
 	if app.DB == nil {
-		return "Хьюстон! У нас проблемы! Отсутствует подключение к базе данных", nil
+		return tr(lang, "mod.db_disconnected"), nil
 	}
 
 	uData, err := app.DB.getUserDBData(userPubkey)
@@ -27,68 +33,90 @@ func (app *solution) viewUserBalance(userPubkey string) (string, error) {
 	}
 
 	if uData == nil {
-		return "Пользователь не найден", nil
+		return tr(lang, "mod.user_not_found"), nil
 	}
 
-	return "На балансе юзера " + formatFloat(uData.Balance) + " б", nil
+	return tr(lang, "mod.user_balance", formatFloat(uData.Balance)), nil
 }
 
-func (app *solution) resetUserPoints(userPubkey string) (string, error) {
+func (app *solution) resetUserPoints(ctx context.Context, lang, userPubkey string) (string, error) {
 	uData, err := app.DB.getUserDBData(userPubkey)
 	if err != nil {
 		return "", err
 	}
+	if uData == nil {
+		return tr(lang, "mod.user_not_found"), nil
+	}
 
 	err = app.DB.resetUserPoints(userPubkey)
 	if err != nil {
 		return "", err
 	}
-	return "Сброс баллов юзера №" + uData.UID + " выполнен", nil
+	if err := app.DB.addPointsHistory(ctx, userPubkey, pointsKindReset, -uData.Balance); err != nil {
+		logger.Error(err)
+	}
+	return tr(lang, "mod.points_reset", uData.UID), nil
 }
 
-func (app *solution) decreaseUserPoints(userPubkey, pointsRaw string) (string, error) {
-	points, err := strconv.ParseFloat(pointsRaw, 64)
-	if err != nil {
-		return "Я не смог разобрать число поинтов для вычета. Формат команды:\n\n" +
-			"вычет ключ количество", nil
+type withdrawResult struct {
+	OldBalance  float64 `json:"old_balance"`
+	Withdrawn   float64 `json:"withdrawn"`
+	NewBalance  float64 `json:"new_balance"`
+	NotifyError string  `json:"notify_error,omitempty"`
+}
+
+// decreases user balance & notifies about withdraw
+func (app *solution) withdrawUserPoints(ctx context.Context, userPubkey string, points float64) (*withdrawResult, error) {
+	if points <= 0 {
+		return nil, errors.New("points to withdraw must be positive")
 	}
 
-	uData, err := app.DB.getUserDBData(userPubkey)
+	// balance is changed by accruals at the same time, so it is decreased by DB
+	uData, err := app.DB.withdrawUserPoints(ctx, userPubkey, points)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	newBalance := uData.Balance - points
-	if newBalance < 0 {
-		newBalance = 0
+	result := withdrawResult{
+		OldBalance: uData.Balance + points,
+		Withdrawn:  points,
+		NewBalance: uData.Balance,
 	}
+	botMetrics.Withdrawals.inc()
+	botMetrics.PointsWithdrawn.add(result.Withdrawn)
+
+	app.handleFraudSignals(app.Fraud.onWithdraw(userPubkey, points, time.Now()))
+	if err = app.sendWithdrawNotify(sendNotifyTask{
+		Nickname: uData.NickName,
+		Amount:   points,
+	}); err != nil {
+		logger.Error(err)
+		result.NotifyError = err.Error()
+	}
+
+	logger.Info("user " + userPubkey + " balance decreased by " + formatFloat(result.Withdrawn))
+	return &result, nil
+}
 
-	err = app.DB.setUserPoints(userPubkey, newBalance)
+func (app *solution) decreaseUserPoints(ctx context.Context, lang, userPubkey, pointsRaw string) (string, error) {
+	points, err := strconv.ParseFloat(pointsRaw, 64)
 	if err != nil {
-		return "", err
+		return tr(lang, "mod.decrease_parse_error"), nil
 	}
 
-	if points > 0 {
-		if err = app.sendWithdrawNotify(sendNotifyTask{
-			Nickname: uData.NickName,
-			Amount:   points,
-		}); err != nil {
-			return "", fmt.Errorf("не удалось отправить оповещение: %w", err)
-		}
+	r, err := app.withdrawUserPoints(ctx, userPubkey, points)
+	switch {
+	case errors.Is(err, errUserNotFound):
+		return tr(lang, "mod.user_not_found"), nil
+	case errors.Is(err, errNotEnoughPoints):
+		return tr(lang, "mod.not_enough_points"), nil
+	case err != nil:
+		return "", err
 	}
 
-	msg := "У юзера было " + formatFloat(uData.Balance) + ", вычли " + formatFloat(points) +
-		", осталось " + formatFloat(newBalance)
-	logger.Info(msg)
-	return msg, nil
-}
-
-func (app *solution) isVoucherCanBeActivated(userPubkey string) bool {
-	timeoutData, isExists := app.VouchersCooldown[userPubkey]
-	if !isExists {
-		app.VouchersCooldown[userPubkey] = time.Now()
-		return true
+	msg := tr(lang, "mod.points_decreased", formatFloat(r.OldBalance), formatFloat(points), formatFloat(r.NewBalance))
+	if r.NotifyError != "" {
+		msg += tr(lang, "mod.notify_failed", r.NotifyError)
 	}
-
-	return time.Since(timeoutData) > gameVoucherActivateTimeout
+	return msg, nil
 }
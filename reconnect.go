@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/google/logger"
+)
+
+const (
+	reconnectStateConnected    = "connected"
+	reconnectStateReconnecting = "reconnecting"
+	reconnectStateCircuitOpen  = "circuit_open"
+)
+
+type reconnectRequest struct {
+	Reason     string
+	WithReboot bool // reboot Utopia before reconnect
+}
+
+// limits automatic Utopia reboots per time window
+type rebootBreaker struct {
+	Limit   int
+	Window  time.Duration
+	Reboots []time.Time
+}
+
+// reconnects Utopia in a separate goroutine so callers never block on it
+type reconnectSupervisor struct {
+	sync.Mutex
+	State    string
+	Requests chan reconnectRequest
+
+	Config        reconnectConfig
+	Breaker       *rebootBreaker
+	Connect       func() error
+	Reboot        func() error
+	Notify        func(message string)
+	OnReconnected func()
+	Sleep         func(ctx context.Context, d time.Duration) bool // false when ctx is canceled
+}
+
+func newReconnectSupervisor(cfg reconnectConfig) *reconnectSupervisor {
+	if cfg.MinBackoffSeconds <= 0 {
+		cfg.MinBackoffSeconds = defaultReconnectMinBackoffSeconds
+	}
+	if cfg.MaxBackoffSeconds < cfg.MinBackoffSeconds {
+		cfg.MaxBackoffSeconds = defaultReconnectMaxBackoffSeconds
+	}
+	if cfg.AttemptsBeforeReboot <= 0 {
+		cfg.AttemptsBeforeReboot = defaultReconnectAttemptsBeforeReboot
+	}
+	if cfg.MaxReboots <= 0 {
+		cfg.MaxReboots = defaultReconnectMaxReboots
+	}
+	if cfg.RebootsWindowMinutes <= 0 {
+		cfg.RebootsWindowMinutes = defaultReconnectRebootsWindowMinutes
+	}
+
+	return &reconnectSupervisor{
+		State:    reconnectStateConnected,
+		Requests: make(chan reconnectRequest, 1),
+		Config:   cfg,
+		Breaker: &rebootBreaker{
+			Limit:  cfg.MaxReboots,
+			Window: time.Duration(cfg.RebootsWindowMinutes) * time.Minute,
+		},
+		Sleep: sleepWithContext,
+	}
+}
+
+func sleepWithContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func (b *rebootBreaker) prune(now time.Time) {
+	reboots := []time.Time{}
+	for _, rebootTime := range b.Reboots {
+		if now.Sub(rebootTime) < b.Window {
+			reboots = append(reboots, rebootTime)
+		}
+	}
+	b.Reboots = reboots
+}
+
+func (b *rebootBreaker) isOpen(now time.Time) bool {
+	b.prune(now)
+	return len(b.Reboots) >= b.Limit
+}
+
+func (b *rebootBreaker) registerReboot(now time.Time) {
+	b.Reboots = append(b.Reboots, now)
+}
+
+// returns time until the next reboot is allowed
+func (b *rebootBreaker) getOpenRemaining(now time.Time) time.Duration {
+	if !b.isOpen(now) {
+		return 0
+	}
+	return b.Window - now.Sub(b.Reboots[0])
+}
+
+// exponential backoff with jitter: random value in [delay/2, delay]
+func getReconnectDelay(attempt int, minDelay, maxDelay time.Duration) time.Duration {
+	delay := minDelay
+	for i := 0; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+
+	half := int64(delay / 2)
+	if half <= 0 {
+		return delay
+	}
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+func (s *reconnectSupervisor) getState() string {
+	s.Lock()
+	defer s.Unlock()
+	return s.State
+}
+
+func (s *reconnectSupervisor) setState(state, details string) {
+	s.Lock()
+	isChanged := s.State != state
+	s.State = state
+	s.Unlock()
+
+	if !isChanged {
+		return
+	}
+
+	message := "Utopia: " + state
+	if details != "" {
+		message += ". " + details
+	}
+	logger.Info(message)
+	if s.Notify != nil {
+		s.Notify(message)
+	}
+}
+
+// does not block: the request is skipped when reconnect is already pending or running,
+// errors during reconnect must not trigger one more reconnect after it
+func (s *reconnectSupervisor) request(r reconnectRequest) {
+	if state := s.getState(); state != reconnectStateConnected {
+		logger.Info("reconnect in progress (" + state + "), skip: " + r.Reason)
+		return
+	}
+
+	select {
+	case s.Requests <- r:
+	default:
+		logger.Info("reconnect already requested, skip: " + r.Reason)
+	}
+}
+
+func (s *reconnectSupervisor) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case r := <-s.Requests:
+			s.reconnect(ctx, r)
+		}
+	}
+}
+
+// drops requests queued before the connection was restored
+func (s *reconnectSupervisor) dropPendingRequests() {
+	for {
+		select {
+		case r := <-s.Requests:
+			logger.Info("connection restored, skip reconnect request: " + r.Reason)
+		default:
+			return
+		}
+	}
+}
+
+func (s *reconnectSupervisor) tryReboot(ctx context.Context) bool {
+	now := time.Now()
+	if s.Breaker.isOpen(now) {
+		s.setState(
+			reconnectStateCircuitOpen,
+			tr(catalog.DefaultLang, "reconnect.circuit_open",
+				s.Breaker.getOpenRemaining(now).Round(time.Second).String(),
+				s.Breaker.Limit, s.Breaker.Window.String(),
+			),
+		)
+		return false
+	}
+
+	s.Breaker.registerReboot(now)
+	if err := s.Reboot(); err != nil {
+		logger.Error(errors.New("failed to reboot Utopia: " + err.Error()))
+		return false
+	}
+
+	logger.Info("wait " + waitAfterUtopiaReboot.String() + " after Utopia reboot..")
+	return s.Sleep(ctx, waitAfterUtopiaReboot)
+}
+
+// blocks until connection is established or ctx is canceled
+func (s *reconnectSupervisor) reconnect(ctx context.Context, r reconnectRequest) {
+	s.setState(reconnectStateReconnecting, r.Reason)
+	startedAt := time.Now()
+
+	if r.WithReboot {
+		s.tryReboot(ctx)
+	}
+
+	minDelay := time.Duration(s.Config.MinBackoffSeconds) * time.Second
+	maxDelay := time.Duration(s.Config.MaxBackoffSeconds) * time.Second
+	for attempt := 0; ; attempt++ {
+		if ctx.Err() != nil {
+			logger.Info("reconnect canceled")
+			return
+		}
+
+		logger.Info("connect to Utopia Network..")
+		err := s.Connect()
+		if err == nil {
+			s.setState(
+				reconnectStateConnected,
+				tr(catalog.DefaultLang, "reconnect.connected",
+					attempt+1, time.Since(startedAt).Round(time.Second).String(),
+				),
+			)
+			s.dropPendingRequests()
+			if s.OnReconnected != nil {
+				s.OnReconnected()
+			}
+			return
+		}
+		logger.Warning("connection failed: " + err.Error())
+
+		if (attempt+1)%s.Config.AttemptsBeforeReboot == 0 && s.tryReboot(ctx) {
+			continue
+		}
+
+		delay := getReconnectDelay(attempt, minDelay, maxDelay)
+		logger.Info("retry after " + delay.Round(time.Second).String() + "..")
+		if !s.Sleep(ctx, delay) {
+			logger.Info("reconnect canceled")
+			return
+		}
+	}
+}
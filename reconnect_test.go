@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRebootBreaker(t *testing.T) {
+	b := rebootBreaker{Limit: 2, Window: time.Hour}
+	now := time.Now()
+
+	b.registerReboot(now.Add(-90 * time.Minute))
+	b.registerReboot(now.Add(-30 * time.Minute))
+	if b.isOpen(now) {
+		t.Fatal("expired reboots must not open the circuit")
+	}
+
+	b.registerReboot(now)
+	if !b.isOpen(now) {
+		t.Fatal("expected open circuit after reaching the limit")
+	}
+	if remaining := b.getOpenRemaining(now); remaining != 30*time.Minute {
+		t.Fatalf("expected 30m until close, got %v", remaining)
+	}
+	if b.isOpen(now.Add(31 * time.Minute)) {
+		t.Fatal("expected closed circuit after the window")
+	}
+}
+
+func TestReconnectDelay(t *testing.T) {
+	minDelay, maxDelay := 5*time.Second, time.Minute
+	for attempt := 0; attempt < 10; attempt++ {
+		expected := minDelay << attempt
+		if expected > maxDelay {
+			expected = maxDelay
+		}
+
+		delay := getReconnectDelay(attempt, minDelay, maxDelay)
+		if delay < expected/2 || delay > expected {
+			t.Fatalf("attempt %v: delay %v out of [%v, %v]", attempt, delay, expected/2, expected)
+		}
+	}
+}
+
+func TestReconnectSupervisorRebootLimit(t *testing.T) {
+	s := newReconnectSupervisor(reconnectConfig{
+		AttemptsBeforeReboot: 2,
+		MaxReboots:           1,
+	})
+	s.Sleep = func(ctx context.Context, d time.Duration) bool { return true }
+
+	attempts, reboots := 0, 0
+	s.Connect = func() error {
+		attempts++
+		if attempts < 7 {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+	s.Reboot = func() error {
+		reboots++
+		return nil
+	}
+	states := []string{}
+	s.Notify = func(message string) {
+		states = append(states, message)
+	}
+
+	s.reconnect(context.Background(), reconnectRequest{Reason: "test"})
+
+	if reboots != 1 {
+		t.Fatalf("expected 1 reboot, got %v", reboots)
+	}
+	if s.getState() != reconnectStateConnected {
+		t.Fatalf("expected connected state, got %q", s.getState())
+	}
+	if len(states) != 3 {
+		t.Fatalf("expected reconnecting, circuit open & connected notifications, got %v", states)
+	}
+}
+
+func TestReconnectSupervisorStaleRequests(t *testing.T) {
+	s := newReconnectSupervisor(reconnectConfig{AttemptsBeforeReboot: 2, MaxReboots: 1})
+	s.Sleep = func(ctx context.Context, d time.Duration) bool { return true }
+	s.Notify = func(message string) {}
+
+	s.setState(reconnectStateReconnecting, "test")
+	s.request(reconnectRequest{Reason: "ws error", WithReboot: true})
+	if len(s.Requests) != 0 {
+		t.Fatal("request must be dropped while reconnecting")
+	}
+
+	// request queued by an error of the old connection
+	s.Connect = func() error {
+		s.Requests <- reconnectRequest{Reason: "stale", WithReboot: true}
+		return nil
+	}
+	s.reconnect(context.Background(), reconnectRequest{Reason: "test"})
+	if len(s.Requests) != 0 {
+		t.Fatal("stale request must be dropped after reconnect")
+	}
+}
+
+func TestReconnectSupervisorCanceled(t *testing.T) {
+	s := newReconnectSupervisor(reconnectConfig{AttemptsBeforeReboot: 100, MaxReboots: 1})
+	s.Notify = func(message string) {}
+	s.Connect = func() error {
+		return errors.New("connection refused")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.reconnect(ctx, reconnectRequest{Reason: "test"})
+
+	if s.getState() == reconnectStateConnected {
+		t.Fatal("canceled reconnect must not report connection")
+	}
+}
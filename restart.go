@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+
+	"github.com/google/logger"
+)
+
+const (
+	restartStrategySystemd = "systemd"
+	restartStrategyDocker  = "docker"
+	restartStrategyCommand = "command"
+	restartStrategyNoop    = "noop"
+
+	restartServiceUtopia = "utopia"
+	restartServiceBot    = "bot"
+	restartServiceServer = "server"
+)
+
+// restarts utopia, bot or the whole server
+type restartStrategy interface {
+	Name() string
+	Restart(service string) error
+}
+
+type commandExecutor interface {
+	Run(name string, args ...string) error
+}
+
+type osCommandExecutor struct{}
+
+func (osCommandExecutor) Run(name string, args ...string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return errors.New(fmt.Sprint(err) + ": " + stderr.String())
+	}
+	return nil
+}
+
+func newRestartStrategy(cfg restartConfig, executor commandExecutor) (restartStrategy, error) {
+	switch cfg.Strategy {
+	default:
+		return nil, errors.New("unknown restart strategy: " + cfg.Strategy)
+	case "", restartStrategySystemd:
+		units := map[string]string{
+			restartServiceUtopia: defaultUtopiaUnit,
+			restartServiceBot:    defaultBotUnit,
+		}
+		for service, unit := range cfg.Targets {
+			units[service] = unit
+		}
+		return systemdRestartStrategy{Units: units, Executor: executor}, nil
+	case restartStrategyDocker:
+		socketPath := cfg.DockerSocket
+		if socketPath == "" {
+			socketPath = defaultDockerSocket
+		}
+		return newDockerRestartStrategy(socketPath, cfg.Targets), nil
+	case restartStrategyCommand:
+		return commandRestartStrategy{Commands: cfg.Commands, Executor: executor}, nil
+	case restartStrategyNoop:
+		return noopRestartStrategy{}, nil
+	}
+}
+
+type systemdRestartStrategy struct {
+	Units    map[string]string // service -> unit name
+	Executor commandExecutor
+}
+
+func (s systemdRestartStrategy) Name() string {
+	return restartStrategySystemd
+}
+
+func (s systemdRestartStrategy) Restart(service string) error {
+	if service == restartServiceServer {
+		return s.Executor.Run("reboot")
+	}
+
+	unit, isFound := s.Units[service]
+	if !isFound || unit == "" {
+		return errors.New("systemd unit for " + service + " is not set")
+	}
+	return s.Executor.Run("/usr/bin/systemctl", "restart", unit)
+}
+
+// restarts containers through docker engine API
+type dockerRestartStrategy struct {
+	Containers map[string]string // service -> container name
+	Client     *http.Client
+	BaseURL    string
+}
+
+func newDockerRestartStrategy(socketPath string, containers map[string]string) dockerRestartStrategy {
+	return dockerRestartStrategy{
+		Containers: containers,
+		BaseURL:    "http://docker",
+		Client: &http.Client{
+			Timeout: dockerRequestTimeout,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+				},
+			},
+		},
+	}
+}
+
+func (s dockerRestartStrategy) Name() string {
+	return restartStrategyDocker
+}
+
+func (s dockerRestartStrategy) Restart(service string) error {
+	container, isFound := s.Containers[service]
+	if !isFound || container == "" {
+		return errors.New("docker container for " + service + " is not set")
+	}
+
+	response, err := s.Client.Post(
+		s.BaseURL+"/containers/"+url.PathEscape(container)+"/restart",
+		"application/json", nil,
+	)
+	if err != nil {
+		return errors.New("failed to restart container: " + err.Error())
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("failed to restart container %s: docker API status %v", container, response.StatusCode)
+	}
+	return nil
+}
+
+type commandRestartStrategy struct {
+	Commands map[string]string // service -> command line
+	Executor commandExecutor
+}
+
+func (s commandRestartStrategy) Name() string {
+	return restartStrategyCommand
+}
+
+func (s commandRestartStrategy) Restart(service string) error {
+	args := strings.Fields(s.Commands[service])
+	if len(args) == 0 {
+		return errors.New("restart command for " + service + " is not set")
+	}
+	return s.Executor.Run(args[0], args[1:]...)
+}
+
+type noopRestartStrategy struct{}
+
+func (noopRestartStrategy) Name() string {
+	return restartStrategyNoop
+}
+
+func (noopRestartStrategy) Restart(service string) error {
+	logger.Info("skip " + service + " restart: noop restart strategy")
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type fakeCommandExecutor struct {
+	Commands []string
+}
+
+func (e *fakeCommandExecutor) Run(name string, args ...string) error {
+	e.Commands = append(e.Commands, strings.Join(append([]string{name}, args...), " "))
+	return nil
+}
+
+func TestSystemdRestartStrategy(t *testing.T) {
+	executor := &fakeCommandExecutor{}
+	strategy, err := newRestartStrategy(restartConfig{
+		Targets: map[string]string{restartServiceBot: "talk2earn"},
+	}, executor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strategy.Name() != restartStrategySystemd {
+		t.Fatalf("expected systemd strategy by default, got %q", strategy.Name())
+	}
+
+	for _, service := range []string{restartServiceUtopia, restartServiceBot, restartServiceServer} {
+		if err := strategy.Restart(service); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := []string{
+		"/usr/bin/systemctl restart startopia",
+		"/usr/bin/systemctl restart talk2earn",
+		"reboot",
+	}
+	if strings.Join(executor.Commands, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("unexpected commands: %v", executor.Commands)
+	}
+}
+
+func TestCommandRestartStrategy(t *testing.T) {
+	executor := &fakeCommandExecutor{}
+	strategy, err := newRestartStrategy(restartConfig{
+		Strategy: restartStrategyCommand,
+		Commands: map[string]string{restartServiceUtopia: "supervisorctl restart utopia"},
+	}, executor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := strategy.Restart(restartServiceUtopia); err != nil {
+		t.Fatal(err)
+	}
+	if len(executor.Commands) != 1 || executor.Commands[0] != "supervisorctl restart utopia" {
+		t.Fatalf("unexpected commands: %v", executor.Commands)
+	}
+
+	if err := strategy.Restart(restartServiceBot); err == nil {
+		t.Fatal("expected error for service without command")
+	}
+}
+
+func TestDockerRestartStrategy(t *testing.T) {
+	var requestPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestPath = r.Method + " " + r.URL.Path
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	strategy := dockerRestartStrategy{
+		Containers: map[string]string{restartServiceUtopia: "utopia"},
+		Client:     server.Client(),
+		BaseURL:    server.URL,
+	}
+	if err := strategy.Restart(restartServiceUtopia); err != nil {
+		t.Fatal(err)
+	}
+	if requestPath != "POST /containers/utopia/restart" {
+		t.Fatalf("unexpected request: %q", requestPath)
+	}
+
+	if err := strategy.Restart(restartServiceServer); err == nil {
+		t.Fatal("expected error for service without container")
+	}
+}
+
+func TestUnknownRestartStrategy(t *testing.T) {
+	if _, err := newRestartStrategy(restartConfig{Strategy: "kubectl"}, &fakeCommandExecutor{}); err == nil {
+		t.Fatal("expected error for unknown strategy")
+	}
+}
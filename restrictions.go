@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/logger"
+)
+
+const (
+	restrictionFreeze = 1 // accrual stopped
+	restrictionBan    = 2 // accrual stopped, auth rejected, messages get banned reply
+	restrictionBlock  = 3 // accrual stopped, auth rejected, messages ignored
+)
+
+type userRestriction struct {
+	Pubkey    string
+	Kind      int
+	Reason    string
+	ExpiresAt int64 // unix timestamp. 0 - never
+	CreatedAt int64 // unix timestamp
+}
+
+type restrictionsCache struct {
+	sync.RWMutex
+	Data map[string]userRestriction // pubkey -> restriction
+}
+
+func (r userRestriction) isActive(now time.Time) bool {
+	return r.ExpiresAt == 0 || r.ExpiresAt > now.Unix()
+}
+
+func getRestrictionName(lang string, kind int) string {
+	switch kind {
+	default:
+		return tr(lang, "restriction.unknown")
+	case restrictionFreeze:
+		return tr(lang, "restriction.freeze")
+	case restrictionBan:
+		return tr(lang, "restriction.ban")
+	case restrictionBlock:
+		return tr(lang, "restriction.block")
+	}
+}
+
+func (app *solution) initRestrictions() error {
+	logger.Info("load user restrictions..")
+
+	restrictions, err := app.DB.getUserRestrictions()
+	if err != nil {
+		return err
+	}
+
+	app.Restrictions.Lock()
+	defer app.Restrictions.Unlock()
+	for _, r := range restrictions {
+		app.Restrictions.Data[r.Pubkey] = r
+	}
+	return nil
+}
+
+// returns active restriction kind or 0
+func (app *solution) getUserRestriction(pubkey string) int {
+	app.Restrictions.RLock()
+	r, isExists := app.Restrictions.Data[pubkey]
+	app.Restrictions.RUnlock()
+
+	if !isExists || !r.isActive(time.Now()) {
+		return 0
+	}
+	return r.Kind
+}
+
+func (app *solution) isAccrualFrozen(pubkey string) bool {
+	return app.getUserRestriction(pubkey) != 0 || app.isUserFraudFlagged(pubkey)
+}
+
+func (app *solution) isAuthRejected(pubkey string) bool {
+	kind := app.getUserRestriction(pubkey)
+	return kind == restrictionBan || kind == restrictionBlock
+}
+
+// args: <pubkey> <expiry> <reason>
+func (app *solution) handleRestrictUser(lang string, kind int, args []string) ([]string, error) {
+	if len(args) < 3 {
+		return nil, errors.New("not enough arguments")
+	}
+
+	pubkey := args[0]
+	if len(pubkey) != 64 {
+		return []string{tr(lang, "mod.pubkey_invalid_length")}, nil
+	}
+	if app.isUserModerator(pubkey) {
+		return []string{tr(lang, "mod.restrict_moderator")}, nil
+	}
+
+	expiresAt, err := parseExpiryTime(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	r := userRestriction{
+		Pubkey:    pubkey,
+		Kind:      kind,
+		Reason:    strings.Join(args[2:], " "),
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now().Unix(),
+	}
+	if err := app.DB.saveUserRestriction(r); err != nil {
+		return nil, err
+	}
+
+	app.Restrictions.Lock()
+	app.Restrictions.Data[pubkey] = r
+	app.Restrictions.Unlock()
+
+	logger.Info("user " + pubkey + " restricted: " + getRestrictionName(catalog.DefaultLang, kind))
+	return []string{tr(lang, "mod.restricted",
+		getRestrictionName(lang, kind), formatUnixTime(r.ExpiresAt), r.Reason,
+	)}, nil
+}
+
+func (app *solution) handleUnrestrictUser(lang, pubkey string) ([]string, error) {
+	if err := app.DB.deleteUserRestriction(pubkey); err != nil {
+		return nil, err
+	}
+
+	app.Restrictions.Lock()
+	delete(app.Restrictions.Data, pubkey)
+	app.Restrictions.Unlock()
+
+	logger.Info("user " + pubkey + " restrictions removed")
+	return []string{tr(lang, "mod.unrestricted")}, nil
+}
+
+func (app *solution) handleRestrictionsList(lang string) ([]string, error) {
+	app.Restrictions.RLock()
+	defer app.Restrictions.RUnlock()
+
+	now := time.Now()
+	msg := ""
+	for _, r := range app.Restrictions.Data {
+		if !r.isActive(now) {
+			continue
+		}
+		msg += tr(lang, "mod.restrictions_item",
+			getRestrictionName(lang, r.Kind), r.Pubkey, formatUnixTime(r.ExpiresAt), r.Reason)
+	}
+
+	if msg == "" {
+		return []string{tr(lang, "mod.restrictions_empty")}, nil
+	}
+	return []string{tr(lang, "mod.restrictions_title") + msg}, nil
+}
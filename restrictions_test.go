@@ -0,0 +1,112 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseExpiryTime(t *testing.T) {
+	now := time.Now()
+	cases := map[string]time.Duration{
+		"3d":  3 * 24 * time.Hour,
+		"72h": 72 * time.Hour,
+		"30m": 30 * time.Minute,
+	}
+	for raw, ttl := range cases {
+		expiresAt, err := parseExpiryTime(raw)
+		if err != nil {
+			t.Fatalf("%q: %v", raw, err)
+		}
+		if expected := now.Add(ttl).Unix(); expiresAt < expected || expiresAt > expected+5 {
+			t.Fatalf("%q: expected %v, got %v", raw, expected, expiresAt)
+		}
+	}
+
+	if expiresAt, err := parseExpiryTime("-"); err != nil || expiresAt != 0 {
+		t.Fatalf("expected no expiry, got %v, %v", expiresAt, err)
+	}
+	for _, raw := range []string{"", "0d", "-1h", "d", "3 days", "forever"} {
+		if _, err := parseExpiryTime(raw); err == nil {
+			t.Fatalf("expected error for %q", raw)
+		}
+	}
+}
+
+func TestUserRestrictionExpiry(t *testing.T) {
+	now := time.Now()
+	if !(userRestriction{}).isActive(now) {
+		t.Fatal("restriction without expiry must be active")
+	}
+	if !(userRestriction{ExpiresAt: now.Add(time.Minute).Unix()}).isActive(now) {
+		t.Fatal("restriction must be active before expiry")
+	}
+	if (userRestriction{ExpiresAt: now.Unix()}).isActive(now) {
+		t.Fatal("restriction must expire")
+	}
+}
+
+func newRestrictionsTestApp(restrictions ...userRestriction) *solution {
+	app := &solution{Restrictions: restrictionsCache{Data: map[string]userRestriction{}}}
+	for _, r := range restrictions {
+		app.Restrictions.Data[r.Pubkey] = r
+	}
+	return app
+}
+
+func TestUserRestrictionDecisions(t *testing.T) {
+	expired := time.Now().Add(-time.Hour).Unix()
+	app := newRestrictionsTestApp(
+		userRestriction{Pubkey: "frozen", Kind: restrictionFreeze},
+		userRestriction{Pubkey: "banned", Kind: restrictionBan},
+		userRestriction{Pubkey: "blocked", Kind: restrictionBlock},
+		userRestriction{Pubkey: "expired", Kind: restrictionBlock, ExpiresAt: expired},
+	)
+
+	cases := []struct {
+		pubkey        string
+		kind          int
+		isFrozen      bool
+		isAuthBlocked bool
+	}{
+		{"frozen", restrictionFreeze, true, false},
+		{"banned", restrictionBan, true, true},
+		{"blocked", restrictionBlock, true, true},
+		{"expired", 0, false, false},
+		{"unknown", 0, false, false},
+	}
+	for _, c := range cases {
+		if kind := app.getUserRestriction(c.pubkey); kind != c.kind {
+			t.Fatalf("%s: expected restriction %v, got %v", c.pubkey, c.kind, kind)
+		}
+		if app.isAccrualFrozen(c.pubkey) != c.isFrozen {
+			t.Fatalf("%s: expected frozen accrual %v", c.pubkey, c.isFrozen)
+		}
+		if app.isAuthRejected(c.pubkey) != c.isAuthBlocked {
+			t.Fatalf("%s: expected rejected auth %v", c.pubkey, c.isAuthBlocked)
+		}
+	}
+}
+
+func TestHandleRestrictUserValidation(t *testing.T) {
+	moderator := strings.Repeat("A", 64)
+	app := newRestrictionsTestApp()
+	app.UtopiaModerators = map[string]struct{}{moderator: {}}
+
+	if _, err := app.handleRestrictUser("en", restrictionBan, []string{moderator, "1d"}); err == nil {
+		t.Fatal("expected error for missing reason")
+	}
+
+	reply, err := app.handleRestrictUser("en", restrictionBan, []string{"short", "1d", "spam"})
+	if err != nil || reply[0] != tr("en", "mod.pubkey_invalid_length") {
+		t.Fatalf("expected invalid pubkey reply, got %v, %v", reply, err)
+	}
+
+	reply, err = app.handleRestrictUser("en", restrictionBan, []string{moderator, "1d", "spam"})
+	if err != nil || reply[0] != tr("en", "mod.restrict_moderator") {
+		t.Fatalf("expected moderator reply, got %v, %v", reply, err)
+	}
+	if app.getUserRestriction(moderator) != 0 {
+		t.Fatal("moderator must not be restricted")
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	utopiago "github.com/Sagleft/utopialib-go"
+	"github.com/google/logger"
+)
+
+// utopialib has no methods for auth requests & contact messages,
+// so they are requested directly
+func (app *solution) utopiaAPIQuery(method string, params, filters map[string]interface{}) ([]interface{}, error) {
+	cfg := app.Config.UtopiaCfg
+	query, err := json.Marshal(utopiago.Query{
+		Method:  method,
+		Token:   cfg.Token,
+		Params:  params,
+		Filters: filters,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	client := http.Client{Timeout: time.Duration(cfg.RequestTimeoutSeconds) * time.Second}
+	response, err := client.Post(
+		cfg.Protocol+"://"+cfg.Host+":"+strconv.Itoa(cfg.Port)+"/api/1.0/",
+		"application/json", bytes.NewReader(query),
+	)
+	if err != nil {
+		return nil, errors.New("failed to send " + method + " request: " + err.Error())
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, errors.New("failed to read " + method + " response: " + err.Error())
+	}
+
+	var result struct {
+		Result []interface{} `json:"result"`
+		Error  string        `json:"error"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, errors.New("failed to decode " + method + " response: " + err.Error())
+	}
+	if result.Error != "" {
+		return nil, errors.New(method + ": " + result.Error)
+	}
+	return result.Result, nil
+}
+
+func (app *solution) getAPIEvents(
+	method string,
+	params, filters map[string]interface{},
+	eventType string,
+) ([]utopiago.WsEvent, error) {
+	items, err := app.utopiaAPIQuery(method, params, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	events := []utopiago.WsEvent{}
+	for _, item := range items {
+		data, isMap := item.(map[string]interface{})
+		if isMap {
+			events = append(events, utopiago.WsEvent{Type: eventType, Data: data})
+		}
+	}
+	return events, nil
+}
+
+// handles events missed while websocket was down
+func (app *solution) resyncAfterReconnect() {
+	logger.Info("resync after reconnect..")
+
+	contacts, err := app.Config.UtopiaCfg.GetContacts("")
+	if err != nil {
+		logger.Error(errors.New("failed to resync contacts: " + err.Error()))
+		return
+	}
+	app.resyncUsersOnline(contacts)
+
+	if err := app.resyncAuthRequests(); err != nil {
+		logger.Error(err)
+	}
+
+	for _, contact := range contacts {
+		if contact.Nick == serviceAccountName {
+			continue
+		}
+		if err := app.resyncContactMessages(contact.Pubkey); err != nil {
+			logger.Error(err)
+		}
+	}
+
+	if err := app.DB.deleteOldProcessedMessages(time.Now().Add(-processedMessagesTTL)); err != nil {
+		logger.Error(err)
+	}
+}
+
+func (app *solution) resyncUsersOnline(contacts []utopiago.ContactData) {
+	contactsOnline := map[string]struct{}{}
+	for _, contact := range contacts {
+		if !isUserOnline(contact) || contact.Nick == serviceAccountName {
+			continue
+		}
+
+		contactsOnline[contact.Pubkey] = struct{}{}
+		if !app.isUserInOnlineData(contact.Pubkey) {
+			app.markUserOnline(contact.Pubkey)
+		}
+	}
+
+	for _, pubkey := range app.getUsersOnlinePubkeys() {
+		if _, isOnline := contactsOnline[pubkey]; !isOnline {
+			app.markUserOffline(pubkey)
+		}
+	}
+}
+
+func (app *solution) resyncAuthRequests() error {
+	events, err := app.getAPIEvents("getAuthorizationRequests", nil, nil, "newAuthorization")
+	if err != nil {
+		return errors.New("failed to get auth requests: " + err.Error())
+	}
+
+	for _, event := range events {
+		app.handleWsEvent(event)
+	}
+	return nil
+}
+
+func getEventMessageID(event utopiago.WsEvent) (int64, error) {
+	id, err := event.GetFloat("id")
+	if err != nil {
+		return 0, errors.New("message ID not found: " + err.Error())
+	}
+	return int64(id), nil
+}
+
+// returns messages after the last processed one sorted by ID.
+// messages without ID can't be compared with processed ones and are skipped
+func getNewContactMessages(events []utopiago.WsEvent, lastMessageID int64) []utopiago.WsEvent {
+	newEvents := []utopiago.WsEvent{}
+	for _, event := range events {
+		if id, err := getEventMessageID(event); err == nil && id > lastMessageID {
+			newEvents = append(newEvents, event)
+		}
+	}
+
+	sort.Slice(newEvents, func(i, j int) bool {
+		idI, _ := getEventMessageID(newEvents[i])
+		idJ, _ := getEventMessageID(newEvents[j])
+		return idI < idJ
+	})
+	return newEvents
+}
+
+func (app *solution) resyncContactMessages(pubkey string) error {
+	lastMessageID, err := app.DB.getLastProcessedMessageID(pubkey)
+	if err != nil {
+		return err
+	}
+
+	// only the last messages: older ones were processed before the websocket was down
+	events, err := app.getAPIEvents("getContactMessages", map[string]interface{}{
+		"pk": pubkey,
+	}, map[string]interface{}{
+		"limit": resyncMessagesLimit,
+	}, "newInstantMessage")
+	if err != nil {
+		return errors.New("failed to get contact messages: " + err.Error())
+	}
+	events = getNewContactMessages(events, lastMessageID)
+
+	if lastMessageID == 0 {
+		// there are no processed messages yet, don't answer the old ones
+		if len(events) > 0 {
+			id, _ := getEventMessageID(events[len(events)-1])
+			_, err := app.DB.markMessageProcessed(app.Ctx, pubkey, id)
+			return err
+		}
+		return nil
+	}
+
+	for _, event := range events {
+		if _, isFound := event.Data["pk"]; !isFound {
+			event.Data["pk"] = pubkey
+		}
+		app.handleWsEvent(event)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	utopiago "github.com/Sagleft/utopialib-go"
+)
+
+func newTestMessageEvent(data map[string]interface{}) utopiago.WsEvent {
+	return utopiago.WsEvent{Type: "newInstantMessage", Data: data}
+}
+
+func TestGetEventMessageID(t *testing.T) {
+	id, err := getEventMessageID(newTestMessageEvent(map[string]interface{}{"id": float64(309)}))
+	if err != nil || id != 309 {
+		t.Fatalf("expected 309, got %v, %v", id, err)
+	}
+
+	if _, err := getEventMessageID(newTestMessageEvent(map[string]interface{}{"text": "hi"})); err == nil {
+		t.Fatal("expected error for message without ID")
+	}
+}
+
+func TestGetNewContactMessages(t *testing.T) {
+	events := []utopiago.WsEvent{
+		newTestMessageEvent(map[string]interface{}{"id": float64(12)}),
+		newTestMessageEvent(map[string]interface{}{"id": float64(10)}),
+		newTestMessageEvent(map[string]interface{}{"text": "no id"}),
+		newTestMessageEvent(map[string]interface{}{"id": float64(11)}),
+		newTestMessageEvent(map[string]interface{}{"id": float64(9)}),
+	}
+
+	ids := []int64{}
+	for _, event := range getNewContactMessages(events, 10) {
+		id, _ := getEventMessageID(event)
+		ids = append(ids, id)
+	}
+	if !reflect.DeepEqual(ids, []int64{11, 12}) {
+		t.Fatalf("expected new messages [11 12], got %v", ids)
+	}
+
+	if len(getNewContactMessages(events, 12)) != 0 {
+		t.Fatal("expected no new messages")
+	}
+	if len(getNewContactMessages(nil, 0)) != 0 {
+		t.Fatal("expected no messages")
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/logger"
+)
+
+// reward rules with the default timezone
+type rewardSchedule struct {
+	Location *time.Location // default for rules
+	Rules    []parsedRewardRule
+}
+
+// reward rule with parsed dates & timezone
+type parsedRewardRule struct {
+	rewardRule
+	Location  *time.Location
+	StartDate time.Time // zero - no start
+	EndDate   time.Time // zero - no end, exclusive
+}
+
+// matched rules change the points rate: rate * multiplier + bonus
+type rewardRate struct {
+	Base       float64 // points per 24h from points_per_24h or intervals
+	Multiplier float64
+	Bonus      float64 // points per 24h
+	Rules      []string
+}
+
+func (r rewardRate) getPointsPer24h() float64 {
+	return r.Base*r.Multiplier + r.Bonus
+}
+
+func newRewardSchedule(timezone string, rules []rewardRule) (*rewardSchedule, error) {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, errors.New("failed to load rewards timezone: " + err.Error())
+	}
+
+	schedule := &rewardSchedule{Location: location}
+	for i, rule := range rules {
+		parsed, err := parseRewardRule(rule, location)
+		if err != nil {
+			return nil, errors.New("invalid reward rule " + rule.getName(i) + ": " + err.Error())
+		}
+		schedule.Rules = append(schedule.Rules, parsed)
+	}
+	return schedule, nil
+}
+
+func (rule rewardRule) getName(index int) string {
+	if rule.Name != "" {
+		return rule.Name
+	}
+	return "#" + strconv.Itoa(index+1)
+}
+
+func parseRewardRule(rule rewardRule, defaultLocation *time.Location) (parsedRewardRule, error) {
+	parsed := parsedRewardRule{rewardRule: rule, Location: defaultLocation}
+	if rule.Timezone != "" {
+		var err error
+		parsed.Location, err = time.LoadLocation(rule.Timezone)
+		if err != nil {
+			return parsed, err
+		}
+	}
+
+	if rule.FromHour < 0 || rule.FromHour > 23 || rule.ToHour < 0 || rule.ToHour > 24 {
+		return parsed, errors.New("hours must be in 0..24")
+	}
+	for _, weekday := range rule.Weekdays {
+		if weekday < 1 || weekday > 7 {
+			return parsed, errors.New("weekdays must be in 1..7, monday is 1")
+		}
+	}
+	if rule.Multiplier < 0 {
+		return parsed, errors.New("multiplier must not be negative")
+	}
+	if parsed.Multiplier == 0 {
+		parsed.Multiplier = 1
+	}
+
+	if rule.StartDate != "" {
+		date, err := time.ParseInLocation(journalLogsTimeFormat, rule.StartDate, parsed.Location)
+		if err != nil {
+			return parsed, err
+		}
+		parsed.StartDate = date
+	}
+	if rule.EndDate != "" {
+		date, err := time.ParseInLocation(journalLogsTimeFormat, rule.EndDate, parsed.Location)
+		if err != nil {
+			return parsed, err
+		}
+		parsed.EndDate = date.AddDate(0, 0, 1) // end date is inclusive
+	}
+	return parsed, nil
+}
+
+// channels are set by ID or name, empty list is for all channels
+func (rule parsedRewardRule) hasChannel(ch channelConfig) bool {
+	if len(rule.Channels) == 0 {
+		return true
+	}
+	for _, channel := range rule.Channels {
+		if channel == ch.ID || (ch.Name != "" && channel == ch.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (rule parsedRewardRule) isActive(now time.Time) bool {
+	t := now.In(rule.Location)
+	if !rule.StartDate.IsZero() && t.Before(rule.StartDate) {
+		return false
+	}
+	if !rule.EndDate.IsZero() && !t.Before(rule.EndDate) {
+		return false
+	}
+
+	if len(rule.Weekdays) > 0 {
+		weekday := (int(t.Weekday())+6)%7 + 1 // monday is 1
+		isFound := false
+		for _, day := range rule.Weekdays {
+			if day == weekday {
+				isFound = true
+				break
+			}
+		}
+		if !isFound {
+			return false
+		}
+	}
+
+	if rule.FromHour == rule.ToHour {
+		return true // all day
+	}
+	hour := t.Hour()
+	if rule.FromHour < rule.ToHour {
+		return hour >= rule.FromHour && hour < rule.ToHour
+	}
+	// night rule: 22 -> 6
+	return hour >= rule.FromHour || hour < rule.ToHour
+}
+
+// nil schedule has no rules
+func (s *rewardSchedule) getRate(ch channelConfig, base float64, now time.Time) rewardRate {
+	rate := rewardRate{Base: base, Multiplier: 1}
+	if s == nil {
+		return rate
+	}
+
+	for i, rule := range s.Rules {
+		if !rule.hasChannel(ch) || !rule.isActive(now) {
+			continue
+		}
+		rate.Multiplier *= rule.Multiplier
+		rate.Bonus += rule.Bonus
+		rate.Rules = append(rate.Rules, rule.getName(i))
+	}
+	return rate
+}
+
+func (app *solution) initRewardRules() error {
+	if app.Config.RewardsTimezone == "" {
+		app.Config.RewardsTimezone = "Local"
+	}
+
+	var err error
+	app.Rewards, err = newRewardSchedule(app.Config.RewardsTimezone, app.Config.RewardRules)
+	if err != nil {
+		return err
+	}
+	if len(app.Rewards.Rules) > 0 {
+		logger.Info("reward rules loaded: " + strconv.Itoa(len(app.Rewards.Rules)))
+	}
+	return nil
+}
+
+// returns points per 24h by points_per_24h or intervals
+func getBasePointsPer24h(rate channelConfig, usersOnline int) float64 {
+	if !rate.UseIntervals {
+		return rate.PointsPer24h
+	}
+
+	// find users online value from intervals
+	// value = points by 1h
+	var pointsBy1h float64 = 0
+	for i := 0; i < len(rate.Intervals); i++ {
+		interval := rate.Intervals[i]
+		if usersOnline >= interval.From && usersOnline <= interval.To {
+			pointsBy1h = interval.Value
+		}
+	}
+	if pointsBy1h == 0 {
+		logger.Error("interval not found to get points per 24h")
+	}
+	return pointsBy1h * 24
+}
+
+// days of the budget & stats start at midnight in rewards timezone
+func (app *solution) getRewardsNow() time.Time {
+	return time.Now().In(app.Rewards.Location)
+}
+
+func (app *solution) getChannelRate(ch channelConfig, usersOnline int, now time.Time) rewardRate {
+	return app.Rewards.getRate(ch, getBasePointsPer24h(ch, usersOnline), now)
+}
+
+// converts points per 24h to points per contacts check
+func (app *solution) getPointsPerTick(pointsPer24h float64) float64 {
+	return pointsPer24h / (24 * 60 * 60 / float64(app.getContactsCronTimeoutSeconds()))
+}
+
+// effective rate of every channel right now
+func (app *solution) handleRewardRatePreview(lang string) ([]string, error) {
+	now := time.Now().In(app.Rewards.Location)
+	msg := tr(lang, "mod.rate_title", now.Format(voucherTimeFormat), app.Rewards.Location.String())
+
+	channels := app.ChannelsOnlineCache
+	if len(channels) == 0 {
+		// no contacts check yet, show rates without online
+		for _, ch := range app.Config.Channels {
+			channels = append(channels, channelOnline{Config: ch})
+		}
+	}
+
+	for _, ch := range channels {
+		rate := app.getChannelRate(ch.Config, ch.UsersOnline, now)
+		rules := tr(lang, "mod.rate_no_rules")
+		if len(rate.Rules) > 0 {
+			rules = strings.Join(rate.Rules, ", ")
+		}
+
+		msg += tr(lang, "mod.rate_item",
+			ch.Config.getName(), ch.UsersOnline,
+			formatFloat(rate.Base), formatFloat(rate.getPointsPer24h()),
+			formatFloat(rate.Multiplier), formatFloat(rate.Bonus), rules,
+			formatFloat(app.getPointsPerTick(rate.getPointsPer24h())),
+		)
+	}
+	return []string{msg}, nil
+}
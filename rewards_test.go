@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRewardRules(t *testing.T) {
+	schedule, err := newRewardSchedule("UTC", []rewardRule{
+		{Name: "weekend", Weekdays: []int{6, 7}, Multiplier: 2},
+		{Name: "night", Channels: []string{"main"}, FromHour: 22, ToHour: 6, Bonus: 24},
+		{Name: "campaign", StartDate: "2022-06-01", EndDate: "2022-06-03", Multiplier: 3},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	main := channelConfig{ID: "C1", Name: "main"}
+	bonus := channelConfig{ID: "C2"}
+
+	// saturday night
+	rate := schedule.getRate(main, 100, time.Date(2022, 6, 4, 23, 0, 0, 0, time.UTC))
+	if rate.getPointsPer24h() != 224 || len(rate.Rules) != 2 {
+		t.Fatalf("unexpected weekend night rate: %+v", rate)
+	}
+	if rate := schedule.getRate(bonus, 100, time.Date(2022, 6, 4, 23, 0, 0, 0, time.UTC)); rate.getPointsPer24h() != 200 {
+		t.Fatalf("night rule must be applied only to the main channel: %+v", rate)
+	}
+
+	// campaign end date is inclusive, friday early morning is a night too
+	if rate := schedule.getRate(main, 100, time.Date(2022, 6, 3, 5, 0, 0, 0, time.UTC)); rate.getPointsPer24h() != 324 {
+		t.Fatalf("unexpected campaign rate: %+v", rate)
+	}
+	if rate := schedule.getRate(main, 100, time.Date(2022, 6, 6, 12, 0, 0, 0, time.UTC)); rate.getPointsPer24h() != 100 {
+		t.Fatalf("no rules expected on monday noon: %+v", rate)
+	}
+
+	if _, err := newRewardSchedule("UTC", []rewardRule{{Weekdays: []int{0}}}); err == nil {
+		t.Fatal("expected error for invalid weekday")
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	utopiago "github.com/Sagleft/utopialib-go"
+	"github.com/google/logger"
+	simplecron "github.com/sagleft/simple-cron"
+)
+
+func (app *solution) isShuttingDown() bool {
+	select {
+	case <-app.ShutdownCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// blocks until SIGINT or SIGTERM
+func (app *solution) waitForShutdownSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	sig := <-signals
+	logger.Info("received " + sig.String() + ", shutdown..")
+}
+
+func (app *solution) getShutdownTimeout() time.Duration {
+	if app.Config.ShutdownTimeoutSeconds <= 0 {
+		return defaultShutdownTimeout
+	}
+	return time.Duration(app.Config.ShutdownTimeoutSeconds) * time.Second
+}
+
+// stops accepting events, finishes in-flight work & closes connections.
+// the root context is canceled before the DB is closed
+func (app *solution) shutdown() {
+	close(app.ShutdownCh)
+	ctx, cancel := context.WithTimeout(app.Ctx, app.getShutdownTimeout())
+	defer cancel()
+
+	app.stopCrons()
+	app.stopUtopiaWs()
+	app.stopTelegramPoller(ctx)
+
+	if err := app.Events.stop(ctx); err != nil {
+		logger.Error(err)
+	}
+	if err := app.waitContactsCheck(ctx); err != nil {
+		logger.Error(err)
+	}
+	if err := app.flushOutboundQueue(ctx); err != nil {
+		logger.Error(err)
+	}
+
+	for _, server := range []*http.Server{app.AdminAPIServer, app.MonitoringServer} {
+		if server == nil {
+			continue
+		}
+		if err := server.Shutdown(ctx); err != nil {
+			logger.Error(err)
+		}
+	}
+
+	app.NLU.Close()
+
+	// handlers still running after the deadline must stop using the DB
+	app.cancelCtx()
+	if err := app.DB.Conn.Close(); err != nil {
+		logger.Error(err)
+	}
+	logger.Info("bot stopped")
+}
+
+func (app *solution) stopCrons() {
+	for _, cron := range []*simplecron.CronObject{
+		app.HandleContactsCron,
+		app.HealthCheckCron,
+		app.VouchersGiveawayCron,
+	} {
+		if cron != nil {
+			cron.Stop()
+		}
+	}
+}
+
+func (app *solution) stopUtopiaWs() {
+	err := app.Config.UtopiaCfg.SetWebSocketState(utopiago.SetWsStateTask{
+		Enabled: false,
+		Port:    app.Config.UtopiaCfg.WsPort,
+	})
+	if err != nil {
+		logger.Error(errors.New("failed to disable websocket: " + err.Error()))
+	}
+	app.Health.setWsConnected(false)
+}
+
+func (app *solution) stopTelegramPoller(ctx context.Context) {
+	app.Health.Lock()
+	isRunning := app.Health.TgPollerRunning
+	app.Health.Unlock()
+	if !isRunning {
+		return
+	}
+
+	// Stop waits for the update in handling
+	done := make(chan struct{})
+	go func() {
+		app.TelegramBot.Stop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		logger.Error("failed to stop telegram poller: " + ctx.Err().Error())
+	}
+}
+
+func (app *solution) waitContactsCheck(ctx context.Context) error {
+	for app.isContactsCheckInProgress() {
+		select {
+		case <-ctx.Done():
+			return errors.New("contacts check is not finished: " + ctx.Err().Error())
+		case <-time.After(shutdownPollInterval):
+		}
+	}
+	return nil
+}
+
+// sends due messages, the rest stay in DB until next start
+func (app *solution) flushOutboundQueue(ctx context.Context) error {
+	select {
+	case <-app.OutboundDone:
+	case <-ctx.Done():
+		return errors.New("outbound queue worker is not stopped: " + ctx.Err().Error())
+	}
+
+	for ctx.Err() == nil {
+		if app.handleOutboundQueue() == 0 {
+			return nil
+		}
+	}
+	return errors.New("outbound queue is not flushed: " + ctx.Err().Error())
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -15,7 +16,187 @@ func (app *solution) sqlDBConnect() error {
 	logger.Info("connect to db..")
 	var err error
 	app.DB, err = newDBHandler(app.Config.DB)
-	return err
+	if err != nil {
+		return err
+	}
+	app.DB.Location = app.Rewards.Location
+	return app.DB.createTables()
+}
+
+// bot-managed tables. users & game_vouchers tables are created manually
+var dbTables = []string{
+	`CREATE TABLE IF NOT EXISTS voucher_batches (
+		id BIGINT NOT NULL AUTO_INCREMENT,
+		amount DOUBLE NOT NULL,
+		codes_count INT NOT NULL,
+		max_uses INT NOT NULL DEFAULT 1,
+		per_user_limit INT NOT NULL DEFAULT 0,
+		expires_at BIGINT NOT NULL DEFAULT 0,
+		created_at BIGINT NOT NULL,
+		revoked TINYINT(1) NOT NULL DEFAULT 0,
+		PRIMARY KEY (id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS voucher_batch_codes (
+		code VARCHAR(64) NOT NULL,
+		batch_id BIGINT NOT NULL,
+		uses INT NOT NULL DEFAULT 0,
+		PRIMARY KEY (code),
+		KEY batch_id (batch_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS voucher_redemptions (
+		id BIGINT NOT NULL AUTO_INCREMENT,
+		batch_id BIGINT NOT NULL,
+		code VARCHAR(64) NOT NULL,
+		pubkey VARCHAR(64) NOT NULL,
+		amount DOUBLE NOT NULL,
+		created_at BIGINT NOT NULL,
+		PRIMARY KEY (id),
+		KEY batch_user (batch_id, pubkey)
+	)`,
+	`CREATE TABLE IF NOT EXISTS voucher_attempts (
+		pubkey VARCHAR(64) NOT NULL,
+		failed INT NOT NULL DEFAULT 0,
+		lockouts INT NOT NULL DEFAULT 0,
+		locked_until BIGINT NOT NULL DEFAULT 0,
+		last_failure BIGINT NOT NULL DEFAULT 0,
+		PRIMARY KEY (pubkey)
+	)`,
+	`CREATE TABLE IF NOT EXISTS fraud_scores (
+		pubkey VARCHAR(64) NOT NULL,
+		score DOUBLE NOT NULL DEFAULT 0,
+		status TINYINT NOT NULL DEFAULT 0,
+		reasons TEXT NOT NULL,
+		updated_at BIGINT NOT NULL,
+		PRIMARY KEY (pubkey),
+		KEY status (status)
+	)`,
+	`CREATE TABLE IF NOT EXISTS user_restrictions (
+		pubkey VARCHAR(64) NOT NULL,
+		kind TINYINT NOT NULL,
+		reason TEXT NOT NULL,
+		expires_at BIGINT NOT NULL DEFAULT 0,
+		created_at BIGINT NOT NULL,
+		PRIMARY KEY (pubkey)
+	)`,
+	`CREATE TABLE IF NOT EXISTS points_history (
+		id BIGINT NOT NULL AUTO_INCREMENT,
+		pubkey VARCHAR(64) NOT NULL,
+		kind VARCHAR(16) NOT NULL,
+		period VARCHAR(32) NOT NULL,
+		amount DOUBLE NOT NULL,
+		created_at BIGINT NOT NULL,
+		PRIMARY KEY (id),
+		UNIQUE KEY movement (pubkey, kind, period),
+		KEY pubkey_time (pubkey, created_at)
+	)`,
+	`CREATE TABLE IF NOT EXISTS processed_messages (
+		pubkey VARCHAR(64) NOT NULL,
+		message_id BIGINT NOT NULL,
+		created_at BIGINT NOT NULL,
+		PRIMARY KEY (pubkey, message_id),
+		KEY created_at (created_at)
+	)`,
+	`CREATE TABLE IF NOT EXISTS outbound_messages (
+		id BIGINT NOT NULL AUTO_INCREMENT,
+		pubkey VARCHAR(64) NOT NULL,
+		text TEXT NOT NULL,
+		priority TINYINT NOT NULL,
+		dedup_key CHAR(64) NULL,
+		status TINYINT NOT NULL,
+		attempts INT NOT NULL DEFAULT 0,
+		next_attempt_at BIGINT NOT NULL,
+		last_error VARCHAR(500) NULL,
+		created_at BIGINT NOT NULL,
+		PRIMARY KEY (id),
+		UNIQUE KEY dedup_key (dedup_key),
+		KEY due (status, next_attempt_at)
+	)`,
+	`CREATE TABLE IF NOT EXISTS user_languages (
+		pubkey VARCHAR(64) NOT NULL,
+		lang VARCHAR(8) NOT NULL,
+		updated_at BIGINT NOT NULL,
+		PRIMARY KEY (pubkey)
+	)`,
+	`CREATE TABLE IF NOT EXISTS faq_entries (
+		id BIGINT NOT NULL AUTO_INCREMENT,
+		questions TEXT NOT NULL,
+		keywords TEXT NOT NULL,
+		answer TEXT NOT NULL,
+		lang VARCHAR(8) NOT NULL DEFAULT '',
+		hits INT NOT NULL DEFAULT 0,
+		updated_at BIGINT NOT NULL,
+		PRIMARY KEY (id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS faq_unanswered (
+		question_hash CHAR(64) NOT NULL,
+		question VARCHAR(500) NOT NULL,
+		lang VARCHAR(8) NOT NULL,
+		last_pubkey VARCHAR(64) NOT NULL,
+		count INT NOT NULL DEFAULT 1,
+		created_at BIGINT NOT NULL,
+		last_at BIGINT NOT NULL,
+		PRIMARY KEY (question_hash),
+		KEY count (count)
+	)`,
+	`CREATE TABLE IF NOT EXISTS support_tickets (
+		id BIGINT NOT NULL AUTO_INCREMENT,
+		pubkey VARCHAR(64) NOT NULL,
+		nick VARCHAR(64) NOT NULL,
+		status TINYINT NOT NULL,
+		assignee_id BIGINT NOT NULL DEFAULT 0,
+		assignee_name VARCHAR(128) NOT NULL DEFAULT '',
+		tg_message_id BIGINT NOT NULL DEFAULT 0,
+		created_at BIGINT NOT NULL,
+		updated_at BIGINT NOT NULL,
+		closed_at BIGINT NOT NULL DEFAULT 0,
+		PRIMARY KEY (id),
+		KEY pubkey_status (pubkey, status),
+		KEY status (status),
+		KEY tg_message_id (tg_message_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS support_messages (
+		id BIGINT NOT NULL AUTO_INCREMENT,
+		ticket_id BIGINT NOT NULL,
+		is_moderator TINYINT(1) NOT NULL DEFAULT 0,
+		author VARCHAR(128) NOT NULL,
+		text TEXT NOT NULL,
+		tg_message_id BIGINT NOT NULL DEFAULT 0,
+		created_at BIGINT NOT NULL,
+		PRIMARY KEY (id),
+		KEY ticket_id (ticket_id),
+		KEY tg_message_id (tg_message_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS online_stats (
+		pubkey VARCHAR(64) NOT NULL,
+		day VARCHAR(10) NOT NULL,
+		minutes INT NOT NULL DEFAULT 0,
+		PRIMARY KEY (pubkey, day)
+	)`,
+	`CREATE TABLE IF NOT EXISTS channel_memberships (
+		pubkey VARCHAR(64) NOT NULL,
+		is_member TINYINT(1) NOT NULL DEFAULT 0,
+		reminders_sent INT NOT NULL DEFAULT 0,
+		last_reminder_at BIGINT NOT NULL DEFAULT 0,
+		last_resumed_at BIGINT NOT NULL DEFAULT 0,
+		updated_at BIGINT NOT NULL,
+		PRIMARY KEY (pubkey)
+	)`,
+	`CREATE TABLE IF NOT EXISTS giveaway_vouchers (
+		code VARCHAR(64) NOT NULL,
+		amount DOUBLE NOT NULL,
+		created_at BIGINT NOT NULL,
+		expires_at BIGINT NOT NULL,
+		PRIMARY KEY (code)
+	)`,
+}
+
+func (db *dbHandler) createTables() error {
+	for _, sqlQuery := range dbTables {
+		if _, err := db.Conn.Exec(sqlQuery); err != nil {
+			return errors.New("failed to create table: " + err.Error())
+		}
+	}
+	return nil
 }
 
 func isSQLErrNoRows(err error) bool {
@@ -124,9 +305,9 @@ func (db *dbHandler) saveUser(user *userData) error {
 	return nil
 }
 
-func (db *dbHandler) addUserPoints(points float64, pubkey string) error {
+func (db *dbHandler) addUserPoints(ctx context.Context, points float64, pubkey string) error {
 	sqlQuery := "UPDATE " + db.UsersTable + " SET greed=greed+? WHERE pubkey=?"
-	result, err := db.Conn.Exec(sqlQuery, points, pubkey)
+	result, err := db.Conn.ExecContext(ctx, sqlQuery, points, pubkey)
 	if err != nil {
 		return err
 	}
@@ -179,6 +360,49 @@ func (db *dbHandler) setUserPoints(pubkey string, points float64) error {
 	return nil
 }
 
+// decreases balance & saves history in one transaction.
+// returns the user with the new balance
+func (db *dbHandler) withdrawUserPoints(ctx context.Context, pubkey string, points float64) (*userData, error) {
+	tx, err := db.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(
+		ctx,
+		"UPDATE "+db.UsersTable+" SET greed=greed-? WHERE pubkey=? AND greed>=?",
+		points, pubkey, points,
+	)
+	if err != nil {
+		return nil, errors.New("failed to withdraw user points: " + err.Error())
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, errors.New("failed to get rows affected count: " + err.Error())
+	}
+
+	user := &userData{Pubkey: pubkey}
+	err = tx.QueryRowContext(
+		ctx,
+		"SELECT uid,greed,nickname FROM "+db.UsersTable+" WHERE pubkey=? LIMIT 1", pubkey,
+	).Scan(&user.UID, &user.Balance, &user.NickName)
+	if err != nil {
+		if isSQLErrNoRows(err) {
+			return nil, errUserNotFound
+		}
+		return nil, errors.New("failed to select user data: " + err.Error())
+	}
+	if rowsAffected == 0 {
+		return user, errNotEnoughPoints
+	}
+
+	if err := db.insertPointsHistory(ctx, tx, pubkey, pointsKindWithdraw, -points); err != nil {
+		return nil, err
+	}
+	return user, tx.Commit()
+}
+
 func (db *dbHandler) updateUserNickname(pubkey, newNickname string) error {
 	sqlQuery := "UPDATE " + db.UsersTable + " SET nickname=? WHERE pubkey=?"
 	_, err := db.Conn.Exec(sqlQuery, LimitStringLength(newNickname, nicknameMaxLength), pubkey)
@@ -230,7 +454,7 @@ func (db *dbHandler) deleteGameVoucher(voucherCode string) error {
 		return errors.New("failed to get rows affected count: " + err.Error())
 	}
 	if rowsAffected == 0 {
-		return errors.New("voucher not found")
+		return errVoucherNotFound
 	}
 	return nil
 }
@@ -247,3 +471,47 @@ func (db *dbHandler) getGameVoucherAmount(voucherCode string) (float64, error) {
 
 	return voucherAmount, nil
 }
+
+func (db *dbHandler) getUsers(offset, limit int) ([]userData, error) {
+	rows, err := db.Conn.Query(
+		"SELECT uid,pubkey,nickname,greed FROM "+db.UsersTable+" ORDER BY uid LIMIT ?, ?",
+		offset, limit,
+	)
+	if err != nil {
+		return nil, errors.New("failed to select users: " + err.Error())
+	}
+	defer rows.Close()
+
+	users := []userData{}
+	for rows.Next() {
+		u := userData{}
+		if err := rows.Scan(&u.UID, &u.Pubkey, &u.NickName, &u.Balance); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+type gameVoucher struct {
+	Code   string  `json:"code"`
+	Amount float64 `json:"amount"`
+}
+
+func (db *dbHandler) getGameVouchers() ([]gameVoucher, error) {
+	rows, err := db.Conn.Query("SELECT code,amount FROM game_vouchers")
+	if err != nil {
+		return nil, errors.New("failed to select vouchers: " + err.Error())
+	}
+	defer rows.Close()
+
+	vouchers := []gameVoucher{}
+	for rows.Next() {
+		v := gameVoucher{}
+		if err := rows.Scan(&v.Code, &v.Amount); err != nil {
+			return nil, err
+		}
+		vouchers = append(vouchers, v)
+	}
+	return vouchers, rows.Err()
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+func (db *dbHandler) getChannelMemberships() ([]channelMembership, error) {
+	rows, err := db.Conn.Query(
+		"SELECT pubkey,is_member,reminders_sent,last_reminder_at,last_resumed_at FROM channel_memberships",
+	)
+	if err != nil {
+		return nil, errors.New("failed to select channel memberships: " + err.Error())
+	}
+	defer rows.Close()
+
+	states := []channelMembership{}
+	for rows.Next() {
+		s := channelMembership{}
+		if err := rows.Scan(&s.Pubkey, &s.IsMember, &s.RemindersSent, &s.LastReminderAt, &s.LastResumedAt); err != nil {
+			return nil, err
+		}
+		states = append(states, s)
+	}
+	return states, rows.Err()
+}
+
+func (db *dbHandler) saveChannelMembership(s channelMembership) error {
+	_, err := db.Conn.Exec(
+		"INSERT INTO channel_memberships SET pubkey=?, is_member=?, reminders_sent=?, last_reminder_at=?, "+
+			"last_resumed_at=?, updated_at=? "+
+			"ON DUPLICATE KEY UPDATE is_member=VALUES(is_member), reminders_sent=VALUES(reminders_sent), "+
+			"last_reminder_at=VALUES(last_reminder_at), last_resumed_at=VALUES(last_resumed_at), "+
+			"updated_at=VALUES(updated_at)",
+		s.Pubkey, s.IsMember, s.RemindersSent, s.LastReminderAt, s.LastResumedAt, time.Now().Unix(),
+	)
+	if err != nil {
+		return errors.New("failed to save channel membership: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+func scanFAQEntry(scan func(dest ...interface{}) error) (faqEntry, error) {
+	e := faqEntry{}
+	var questions, keywords string
+	if err := scan(&e.ID, &questions, &keywords, &e.Answer, &e.Lang, &e.Hits, &e.UpdatedAt); err != nil {
+		return e, err
+	}
+	e.Questions = splitFAQList(questions, "\n")
+	e.Keywords = splitFAQList(keywords, ",")
+	return e, nil
+}
+
+const faqEntryFields = "id,questions,keywords,answer,lang,hits,updated_at"
+
+func (db *dbHandler) getFAQEntries() ([]faqEntry, error) {
+	rows, err := db.Conn.Query("SELECT " + faqEntryFields + " FROM faq_entries ORDER BY id")
+	if err != nil {
+		return nil, errors.New("failed to select faq entries: " + err.Error())
+	}
+	defer rows.Close()
+
+	entries := []faqEntry{}
+	for rows.Next() {
+		e, err := scanFAQEntry(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// returns nil when entry not found
+func (db *dbHandler) getFAQEntry(id int64) (*faqEntry, error) {
+	e, err := scanFAQEntry(db.Conn.QueryRow(
+		"SELECT "+faqEntryFields+" FROM faq_entries WHERE id=?", id,
+	).Scan)
+	if err != nil {
+		if isSQLErrNoRows(err) {
+			return nil, nil
+		}
+		return nil, errors.New("failed to get faq entry: " + err.Error())
+	}
+	return &e, nil
+}
+
+// creates entry when ID is 0
+func (db *dbHandler) saveFAQEntry(e *faqEntry) error {
+	e.UpdatedAt = time.Now().Unix()
+	questions := strings.Join(e.Questions, "\n")
+	keywords := strings.Join(e.Keywords, ",")
+
+	if e.ID > 0 {
+		_, err := db.Conn.Exec(
+			"UPDATE faq_entries SET questions=?, keywords=?, answer=?, lang=?, updated_at=? WHERE id=?",
+			questions, keywords, e.Answer, e.Lang, e.UpdatedAt, e.ID,
+		)
+		if err != nil {
+			return errors.New("failed to update faq entry: " + err.Error())
+		}
+		return nil
+	}
+
+	result, err := db.Conn.Exec(
+		"INSERT INTO faq_entries SET questions=?, keywords=?, answer=?, lang=?, updated_at=?",
+		questions, keywords, e.Answer, e.Lang, e.UpdatedAt,
+	)
+	if err != nil {
+		return errors.New("failed to save faq entry: " + err.Error())
+	}
+	e.ID, err = result.LastInsertId()
+	return err
+}
+
+func (db *dbHandler) deleteFAQEntry(id int64) error {
+	result, err := db.Conn.Exec("DELETE FROM faq_entries WHERE id=?", id)
+	if err != nil {
+		return errors.New("failed to delete faq entry: " + err.Error())
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return errors.New("failed to get rows affected count: " + err.Error())
+	}
+	if rowsAffected == 0 {
+		return errors.New("faq entry not found")
+	}
+	return nil
+}
+
+func (db *dbHandler) increaseFAQEntryHits(id int64) error {
+	_, err := db.Conn.Exec("UPDATE faq_entries SET hits=hits+1 WHERE id=?", id)
+	if err != nil {
+		return errors.New("failed to update faq entry hits: " + err.Error())
+	}
+	return nil
+}
+
+func (db *dbHandler) saveFAQUnanswered(hash, question, lang, pubkey string) error {
+	now := time.Now().Unix()
+	_, err := db.Conn.Exec(
+		"INSERT INTO faq_unanswered SET question_hash=?, question=?, lang=?, last_pubkey=?, count=1, "+
+			"created_at=?, last_at=? ON DUPLICATE KEY UPDATE count=count+1, "+
+			"last_pubkey=VALUES(last_pubkey), last_at=VALUES(last_at)",
+		hash, question, lang, pubkey, now, now,
+	)
+	if err != nil {
+		return errors.New("failed to save unanswered question: " + err.Error())
+	}
+	return nil
+}
+
+// returns most asked questions first
+func (db *dbHandler) getFAQUnanswered(limit int) ([]faqUnanswered, error) {
+	rows, err := db.Conn.Query(
+		"SELECT question,lang,count,last_at FROM faq_unanswered ORDER BY count DESC, last_at DESC LIMIT ?",
+		limit,
+	)
+	if err != nil {
+		return nil, errors.New("failed to select unanswered questions: " + err.Error())
+	}
+	defer rows.Close()
+
+	questions := []faqUnanswered{}
+	for rows.Next() {
+		q := faqUnanswered{}
+		if err := rows.Scan(&q.Question, &q.Lang, &q.Count, &q.LastAt); err != nil {
+			return nil, err
+		}
+		questions = append(questions, q)
+	}
+	return questions, rows.Err()
+}
+
+func (db *dbHandler) clearFAQUnanswered() error {
+	if _, err := db.Conn.Exec("DELETE FROM faq_unanswered"); err != nil {
+		return errors.New("failed to clear unanswered questions: " + err.Error())
+	}
+	return nil
+}
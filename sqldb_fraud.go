@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+func (db *dbHandler) getFraudScore(pubkey string) (*fraudScore, error) {
+	s := fraudScore{Pubkey: pubkey}
+	err := db.Conn.QueryRow(
+		"SELECT score,status,reasons,updated_at FROM fraud_scores WHERE pubkey=?",
+		pubkey,
+	).Scan(&s.Score, &s.Status, &s.Reasons, &s.UpdatedAt)
+	if err != nil {
+		if isSQLErrNoRows(err) {
+			return nil, nil
+		}
+		return nil, errors.New("failed to select fraud score: " + err.Error())
+	}
+	return &s, nil
+}
+
+// returns updated score data
+func (db *dbHandler) addFraudScore(signal fraudSignal) (*fraudScore, error) {
+	s, err := db.getFraudScore(signal.Pubkey)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		s = &fraudScore{Pubkey: signal.Pubkey}
+	}
+
+	now := time.Now()
+	s.Score = getDecayedFraudScore(s.Score, s.UpdatedAt, now) + signal.Score
+	s.UpdatedAt = now.Unix()
+	if !strings.Contains("\n"+s.Reasons+"\n", "\n"+signal.Reason+"\n") {
+		if s.Reasons != "" {
+			s.Reasons += "\n"
+		}
+		s.Reasons = LimitStringLength(s.Reasons+signal.Reason, fraudReasonsMaxLength)
+	}
+
+	_, err = db.Conn.Exec(
+		"INSERT INTO fraud_scores SET pubkey=?, score=?, status=?, reasons=?, updated_at=? "+
+			"ON DUPLICATE KEY UPDATE score=VALUES(score), reasons=VALUES(reasons), updated_at=VALUES(updated_at)",
+		s.Pubkey, s.Score, s.Status, s.Reasons, s.UpdatedAt,
+	)
+	if err != nil {
+		return nil, errors.New("failed to save fraud score: " + err.Error())
+	}
+	return s, nil
+}
+
+func (db *dbHandler) setFraudStatus(pubkey string, status int) error {
+	_, err := db.Conn.Exec(
+		"UPDATE fraud_scores SET status=?, updated_at=? WHERE pubkey=?",
+		status, time.Now().Unix(), pubkey,
+	)
+	return err
+}
+
+func (db *dbHandler) clearFraudScore(pubkey string) error {
+	result, err := db.Conn.Exec(
+		"UPDATE fraud_scores SET score=0, status=?, updated_at=? WHERE pubkey=?",
+		fraudStatusCleared, time.Now().Unix(), pubkey,
+	)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return errors.New("failed to get rows affected count: " + err.Error())
+	}
+	if rowsAffected == 0 {
+		return errors.New("user not found in fraud scores")
+	}
+	return nil
+}
+
+func (db *dbHandler) getFlaggedPubkeys() ([]string, error) {
+	rows, err := db.Conn.Query("SELECT pubkey FROM fraud_scores WHERE status=?", fraudStatusFlagged)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pubkeys := []string{}
+	for rows.Next() {
+		var pubkey string
+		if err := rows.Scan(&pubkey); err != nil {
+			return nil, err
+		}
+		pubkeys = append(pubkeys, pubkey)
+	}
+	return pubkeys, rows.Err()
+}
+
+func (db *dbHandler) getFraudScores(status, limit int) ([]fraudScore, error) {
+	rows, err := db.Conn.Query(
+		"SELECT pubkey,score,status,reasons,updated_at FROM fraud_scores WHERE status=? "+
+			"ORDER BY score DESC LIMIT ?",
+		status, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	scores := []fraudScore{}
+	for rows.Next() {
+		s := fraudScore{}
+		if err := rows.Scan(&s.Pubkey, &s.Score, &s.Status, &s.Reasons, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		scores = append(scores, s)
+	}
+	return scores, rows.Err()
+}
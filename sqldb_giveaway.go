@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+// saves the code as a single-use voucher and remembers it as a giveaway
+func (db *dbHandler) saveGiveawayVoucher(v giveawayVoucher) error {
+	tx, err := db.Conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("INSERT INTO game_vouchers SET code=?, amount=?", v.Code, v.Amount); err != nil {
+		return errors.New("failed to save voucher: " + err.Error())
+	}
+	if _, err := tx.Exec(
+		"INSERT INTO giveaway_vouchers SET code=?, amount=?, created_at=?, expires_at=?",
+		v.Code, v.Amount, v.CreatedAt.Unix(), v.ExpiresAt.Unix(),
+	); err != nil {
+		return errors.New("failed to save giveaway voucher: " + err.Error())
+	}
+	return tx.Commit()
+}
+
+func (db *dbHandler) getGiveawayVouchers() ([]giveawayVoucher, error) {
+	rows, err := db.Conn.Query("SELECT code,amount,created_at,expires_at FROM giveaway_vouchers")
+	if err != nil {
+		return nil, errors.New("failed to select giveaway vouchers: " + err.Error())
+	}
+	defer rows.Close()
+
+	vouchers := []giveawayVoucher{}
+	for rows.Next() {
+		v := giveawayVoucher{}
+		var createdAt, expiresAt int64
+		if err := rows.Scan(&v.Code, &v.Amount, &createdAt, &expiresAt); err != nil {
+			return nil, err
+		}
+		v.CreatedAt = time.Unix(createdAt, 0)
+		v.ExpiresAt = time.Unix(expiresAt, 0)
+		vouchers = append(vouchers, v)
+	}
+	return vouchers, rows.Err()
+}
+
+// forgets the giveaway, the voucher itself is kept when it was activated
+func (db *dbHandler) deleteGiveawayVoucher(code string, withVoucher bool) error {
+	tx, err := db.Conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if withVoucher {
+		if _, err := tx.Exec("DELETE FROM game_vouchers WHERE code=?", code); err != nil {
+			return errors.New("failed to delete voucher: " + err.Error())
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM giveaway_vouchers WHERE code=?", code); err != nil {
+		return errors.New("failed to delete giveaway voucher: " + err.Error())
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+)
+
+const (
+	pointsKindAccrual  = "accrual"
+	pointsKindVoucher  = "voucher"
+	pointsKindWithdraw = "withdraw"
+	pointsKindReset    = "reset"
+)
+
+type pointsMovement struct {
+	Kind      string  `json:"kind"`
+	Amount    float64 `json:"amount"`
+	Period    string  `json:"period"`     // accrual day or unique movement ID
+	CreatedAt int64   `json:"created_at"` // unix timestamp
+}
+
+type sqlExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// returns the day in rewards timezone
+func (db *dbHandler) getDay(t time.Time) string {
+	if db.Location != nil {
+		t = t.In(db.Location)
+	}
+	return t.Format(journalLogsTimeFormat)
+}
+
+// accruals are summed by day, other movements are saved separately
+func (db *dbHandler) insertPointsHistory(
+	ctx context.Context, exec sqlExecutor, pubkey, kind string, amount float64,
+) error {
+	now := time.Now()
+	period := db.getDay(now)
+	if kind != pointsKindAccrual {
+		period = strconv.FormatInt(now.UnixNano(), 10)
+	}
+
+	_, err := exec.ExecContext(
+		ctx,
+		"INSERT INTO points_history SET pubkey=?, kind=?, period=?, amount=?, created_at=? "+
+			"ON DUPLICATE KEY UPDATE amount=amount+VALUES(amount), created_at=VALUES(created_at)",
+		pubkey, kind, period, amount, now.Unix(),
+	)
+	if err != nil {
+		return errors.New("failed to save points history: " + err.Error())
+	}
+	return nil
+}
+
+func (db *dbHandler) addPointsHistory(ctx context.Context, pubkey, kind string, amount float64) error {
+	return db.insertPointsHistory(ctx, db.Conn, pubkey, kind, amount)
+}
+
+// returns accrued & voucher points since the timestamp
+func (db *dbHandler) getIssuedPointsByKind(since int64) (float64, float64, error) {
+	rows, err := db.Conn.Query(
+		"SELECT kind,SUM(amount) FROM points_history WHERE kind IN (?,?) AND created_at>=? GROUP BY kind",
+		pointsKindAccrual, pointsKindVoucher, since,
+	)
+	if err != nil {
+		return 0, 0, errors.New("failed to select issued points: " + err.Error())
+	}
+	defer rows.Close()
+
+	var accrued, vouchers float64
+	for rows.Next() {
+		var kind string
+		var amount float64
+		if err := rows.Scan(&kind, &amount); err != nil {
+			return 0, 0, err
+		}
+		if kind == pointsKindVoucher {
+			vouchers = amount
+		} else {
+			accrued = amount
+		}
+	}
+	return accrued, vouchers, rows.Err()
+}
+
+func (db *dbHandler) getIssuedPoints(since int64) (float64, error) {
+	accrued, vouchers, err := db.getIssuedPointsByKind(since)
+	return accrued + vouchers, err
+}
+
+func (db *dbHandler) getPointsHistory(pubkey string, limit int) ([]pointsMovement, error) {
+	rows, err := db.Conn.Query(
+		"SELECT kind,amount,period,created_at FROM points_history WHERE pubkey=? "+
+			"ORDER BY created_at DESC LIMIT ?",
+		pubkey, limit,
+	)
+	if err != nil {
+		return nil, errors.New("failed to select points history: " + err.Error())
+	}
+	defer rows.Close()
+
+	movements := []pointsMovement{}
+	for rows.Next() {
+		m := pointsMovement{}
+		if err := rows.Scan(&m.Kind, &m.Amount, &m.Period, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		movements = append(movements, m)
+	}
+	return movements, rows.Err()
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+// returns map[pubkey]lang
+func (db *dbHandler) getUserLanguages() (map[string]string, error) {
+	rows, err := db.Conn.Query("SELECT pubkey,lang FROM user_languages")
+	if err != nil {
+		return nil, errors.New("failed to select user languages: " + err.Error())
+	}
+	defer rows.Close()
+
+	languages := map[string]string{}
+	for rows.Next() {
+		var pubkey, lang string
+		if err := rows.Scan(&pubkey, &lang); err != nil {
+			return nil, err
+		}
+		languages[pubkey] = lang
+	}
+	return languages, rows.Err()
+}
+
+func (db *dbHandler) saveUserLanguage(pubkey, lang string) error {
+	_, err := db.Conn.Exec(
+		"INSERT INTO user_languages SET pubkey=?, lang=?, updated_at=? "+
+			"ON DUPLICATE KEY UPDATE lang=VALUES(lang), updated_at=VALUES(updated_at)",
+		pubkey, lang, time.Now().Unix(),
+	)
+	if err != nil {
+		return errors.New("failed to save user language: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// returns false when the message was already processed
+func (db *dbHandler) markMessageProcessed(ctx context.Context, pubkey string, messageID int64) (bool, error) {
+	result, err := db.Conn.ExecContext(ctx,
+		"INSERT IGNORE INTO processed_messages SET pubkey=?, message_id=?, created_at=?",
+		pubkey, messageID, time.Now().Unix(),
+	)
+	if err != nil {
+		return false, errors.New("failed to mark message processed: " + err.Error())
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.New("failed to get rows affected count: " + err.Error())
+	}
+	return rowsAffected > 0, nil
+}
+
+// returns 0 when user messages were never processed
+func (db *dbHandler) getLastProcessedMessageID(pubkey string) (int64, error) {
+	var messageID sql.NullInt64
+	err := db.Conn.QueryRow(
+		"SELECT MAX(message_id) FROM processed_messages WHERE pubkey=?", pubkey,
+	).Scan(&messageID)
+	if err != nil {
+		return 0, errors.New("failed to get last processed message: " + err.Error())
+	}
+	return messageID.Int64, nil
+}
+
+// keeps the last processed message of each user
+func (db *dbHandler) deleteOldProcessedMessages(before time.Time) error {
+	_, err := db.Conn.Exec(
+		"DELETE pm FROM processed_messages pm "+
+			"JOIN (SELECT pubkey, MAX(message_id) AS last_id FROM processed_messages GROUP BY pubkey) last "+
+			"ON pm.pubkey=last.pubkey WHERE pm.created_at<? AND pm.message_id<last.last_id",
+		before.Unix(),
+	)
+	if err != nil {
+		return errors.New("failed to delete old processed messages: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// returns false when the same message is already in queue
+func (db *dbHandler) enqueueOutboundMessage(m outboundMessage) (bool, error) {
+	var dedupKey interface{}
+	if m.DedupKey != "" {
+		dedupKey = m.DedupKey
+	}
+
+	result, err := db.Conn.Exec(
+		"INSERT INTO outbound_messages SET pubkey=?, text=?, priority=?, dedup_key=?, "+
+			"status=?, next_attempt_at=?, created_at=? ON DUPLICATE KEY UPDATE id=id",
+		m.Pubkey, m.Text, m.Priority, dedupKey,
+		outboundStatusPending, m.CreatedAt, m.CreatedAt,
+	)
+	if err != nil {
+		return false, errors.New("failed to enqueue message: " + err.Error())
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.New("failed to get rows affected count: " + err.Error())
+	}
+	return rowsAffected > 0, nil
+}
+
+func scanOutboundMessages(rows *sql.Rows) ([]outboundMessage, error) {
+	defer rows.Close()
+
+	messages := []outboundMessage{}
+	for rows.Next() {
+		m := outboundMessage{}
+		var lastError sql.NullString
+		err := rows.Scan(
+			&m.ID, &m.Pubkey, &m.Text, &m.Priority, &m.Attempts,
+			&m.NextAttemptAt, &lastError, &m.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		m.LastError = lastError.String
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+const outboundMessageFields = "id,pubkey,text,priority,attempts,next_attempt_at,last_error,created_at"
+
+func (db *dbHandler) getDueOutboundMessages(limit int) ([]outboundMessage, error) {
+	rows, err := db.Conn.Query(
+		"SELECT "+outboundMessageFields+" FROM outbound_messages "+
+			"WHERE status=? AND next_attempt_at<=? ORDER BY priority DESC, id ASC LIMIT ?",
+		outboundStatusPending, time.Now().Unix(), limit,
+	)
+	if err != nil {
+		return nil, errors.New("failed to select outbound messages: " + err.Error())
+	}
+	return scanOutboundMessages(rows)
+}
+
+func (db *dbHandler) getDeadOutboundMessages(limit int) ([]outboundMessage, error) {
+	rows, err := db.Conn.Query(
+		"SELECT "+outboundMessageFields+" FROM outbound_messages "+
+			"WHERE status=? ORDER BY id DESC LIMIT ?",
+		outboundStatusDead, limit,
+	)
+	if err != nil {
+		return nil, errors.New("failed to select dead messages: " + err.Error())
+	}
+	return scanOutboundMessages(rows)
+}
+
+func (db *dbHandler) deleteOutboundMessage(id int64) error {
+	if _, err := db.Conn.Exec("DELETE FROM outbound_messages WHERE id=?", id); err != nil {
+		return errors.New("failed to delete outbound message: " + err.Error())
+	}
+	return nil
+}
+
+// schedules next attempt or moves the message to dead letters
+func (db *dbHandler) saveOutboundMessageFailure(m outboundMessage, isDead bool) error {
+	query := "UPDATE outbound_messages SET status=?, attempts=?, next_attempt_at=?, last_error=?"
+	status := outboundStatusPending
+	if isDead {
+		// the same message can be queued again
+		query += ", dedup_key=NULL"
+		status = outboundStatusDead
+	}
+
+	_, err := db.Conn.Exec(query+" WHERE id=?", status, m.Attempts, m.NextAttemptAt, m.LastError, m.ID)
+	if err != nil {
+		return errors.New("failed to update outbound message: " + err.Error())
+	}
+	return nil
+}
+
+// id 0 retries all dead messages
+func (db *dbHandler) retryDeadOutboundMessages(id int64) (int64, error) {
+	query := "UPDATE outbound_messages SET status=?, attempts=0, next_attempt_at=? WHERE status=?"
+	args := []interface{}{outboundStatusPending, time.Now().Unix(), outboundStatusDead}
+	if id != 0 {
+		query += " AND id=?"
+		args = append(args, id)
+	}
+
+	result, err := db.Conn.Exec(query, args...)
+	if err != nil {
+		return 0, errors.New("failed to retry dead messages: " + err.Error())
+	}
+	return result.RowsAffected()
+}
+
+func (db *dbHandler) getOutboundQueueStats() (pending int, dead int, err error) {
+	rows, err := db.Conn.Query("SELECT status, COUNT(*) FROM outbound_messages GROUP BY status")
+	if err != nil {
+		return 0, 0, errors.New("failed to get outbound queue stats: " + err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var status, count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return 0, 0, err
+		}
+		switch status {
+		case outboundStatusPending:
+			pending = count
+		case outboundStatusDead:
+			dead = count
+		}
+	}
+	return pending, dead, rows.Err()
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+// returns not expired restrictions
+func (db *dbHandler) getUserRestrictions() ([]userRestriction, error) {
+	rows, err := db.Conn.Query(
+		"SELECT pubkey,kind,reason,expires_at,created_at FROM user_restrictions "+
+			"WHERE expires_at=0 OR expires_at>?",
+		time.Now().Unix(),
+	)
+	if err != nil {
+		return nil, errors.New("failed to select user restrictions: " + err.Error())
+	}
+	defer rows.Close()
+
+	restrictions := []userRestriction{}
+	for rows.Next() {
+		r := userRestriction{}
+		if err := rows.Scan(&r.Pubkey, &r.Kind, &r.Reason, &r.ExpiresAt, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		restrictions = append(restrictions, r)
+	}
+	return restrictions, rows.Err()
+}
+
+func (db *dbHandler) saveUserRestriction(r userRestriction) error {
+	_, err := db.Conn.Exec(
+		"INSERT INTO user_restrictions SET pubkey=?, kind=?, reason=?, expires_at=?, created_at=? "+
+			"ON DUPLICATE KEY UPDATE kind=VALUES(kind), reason=VALUES(reason), "+
+			"expires_at=VALUES(expires_at), created_at=VALUES(created_at)",
+		r.Pubkey, r.Kind, r.Reason, r.ExpiresAt, r.CreatedAt,
+	)
+	if err != nil {
+		return errors.New("failed to save user restriction: " + err.Error())
+	}
+	return nil
+}
+
+func (db *dbHandler) deleteUserRestriction(pubkey string) error {
+	result, err := db.Conn.Exec("DELETE FROM user_restrictions WHERE pubkey=?", pubkey)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return errors.New("failed to get rows affected count: " + err.Error())
+	}
+	if rowsAffected == 0 {
+		return errors.New("user has no restrictions")
+	}
+	return nil
+}
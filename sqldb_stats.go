@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+func (db *dbHandler) addOnlineMinutes(ctx context.Context, pubkey string, minutes int) error {
+	_, err := db.Conn.ExecContext(
+		ctx,
+		"INSERT INTO online_stats SET pubkey=?, day=?, minutes=? "+
+			"ON DUPLICATE KEY UPDATE minutes=minutes+VALUES(minutes)",
+		pubkey, db.getDay(time.Now()), minutes,
+	)
+	if err != nil {
+		return errors.New("failed to save online stats: " + err.Error())
+	}
+	return nil
+}
+
+// returns minutes online in channel since the day, inclusive
+func (db *dbHandler) getOnlineMinutes(pubkey, fromDay string) (int, error) {
+	var minutes int
+	err := db.Conn.QueryRow(
+		"SELECT COALESCE(SUM(minutes),0) FROM online_stats WHERE pubkey=? AND day>=?",
+		pubkey, fromDay,
+	).Scan(&minutes)
+	if err != nil {
+		return 0, errors.New("failed to select online stats: " + err.Error())
+	}
+	return minutes, nil
+}
+
+// returns points accrued for being online during the day
+func (db *dbHandler) getAccruedPoints(pubkey, day string) (float64, error) {
+	var points float64
+	err := db.Conn.QueryRow(
+		"SELECT COALESCE(SUM(amount),0) FROM points_history WHERE pubkey=? AND kind=? AND period=?",
+		pubkey, pointsKindAccrual, day,
+	).Scan(&points)
+	if err != nil {
+		return 0, errors.New("failed to select accrued points: " + err.Error())
+	}
+	return points, nil
+}
+
+// returns pubkey -> points accrued for the day
+func (db *dbHandler) getAccruedPointsByUser(day string) (map[string]float64, error) {
+	rows, err := db.Conn.Query(
+		"SELECT pubkey, SUM(amount) FROM points_history WHERE kind=? AND period=? GROUP BY pubkey",
+		pointsKindAccrual, day,
+	)
+	if err != nil {
+		return nil, errors.New("failed to select accrued points: " + err.Error())
+	}
+	defer rows.Close()
+
+	users := map[string]float64{}
+	for rows.Next() {
+		var pubkey string
+		var points float64
+		if err := rows.Scan(&pubkey, &points); err != nil {
+			return nil, err
+		}
+		users[pubkey] = points
+	}
+	return users, rows.Err()
+}
+
+// returns user place by balance and users count
+func (db *dbHandler) getUserRank(balance float64) (int, int, error) {
+	var rank, total int
+	err := db.Conn.QueryRow(
+		"SELECT COUNT(*) FROM "+db.UsersTable+" WHERE greed>?", balance,
+	).Scan(&rank)
+	if err != nil {
+		return 0, 0, errors.New("failed to select user rank: " + err.Error())
+	}
+
+	err = db.Conn.QueryRow("SELECT COUNT(*) FROM " + db.UsersTable).Scan(&total)
+	if err != nil {
+		return 0, 0, errors.New("failed to select users count: " + err.Error())
+	}
+	return rank + 1, total, nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+const supportTicketFields = "id,pubkey,nick,status,assignee_id,assignee_name,tg_message_id,created_at,updated_at,closed_at"
+
+func scanSupportTicket(scan func(dest ...interface{}) error) (supportTicket, error) {
+	t := supportTicket{}
+	err := scan(&t.ID, &t.Pubkey, &t.Nick, &t.Status, &t.AssigneeID, &t.AssigneeName,
+		&t.TgMessageID, &t.CreatedAt, &t.UpdatedAt, &t.ClosedAt)
+	return t, err
+}
+
+// creates ticket when ID is 0
+func (db *dbHandler) saveSupportTicket(t *supportTicket) error {
+	now := time.Now().Unix()
+	t.UpdatedAt = now
+	if t.Status == supportTicketClosed && t.ClosedAt == 0 {
+		t.ClosedAt = now
+	}
+
+	if t.ID > 0 {
+		_, err := db.Conn.Exec(
+			"UPDATE support_tickets SET status=?, assignee_id=?, assignee_name=?, tg_message_id=?, "+
+				"updated_at=?, closed_at=? WHERE id=?",
+			t.Status, t.AssigneeID, t.AssigneeName, t.TgMessageID, t.UpdatedAt, t.ClosedAt, t.ID,
+		)
+		if err != nil {
+			return errors.New("failed to update support ticket: " + err.Error())
+		}
+		return nil
+	}
+
+	t.CreatedAt = now
+	result, err := db.Conn.Exec(
+		"INSERT INTO support_tickets SET pubkey=?, nick=?, status=?, assignee_id=?, assignee_name=?, "+
+			"tg_message_id=?, created_at=?, updated_at=?, closed_at=?",
+		t.Pubkey, t.Nick, t.Status, t.AssigneeID, t.AssigneeName, t.TgMessageID, t.CreatedAt, t.UpdatedAt, t.ClosedAt,
+	)
+	if err != nil {
+		return errors.New("failed to save support ticket: " + err.Error())
+	}
+	t.ID, err = result.LastInsertId()
+	return err
+}
+
+// returns nil when ticket not found
+func (db *dbHandler) getSupportTicket(id int64) (*supportTicket, error) {
+	t, err := scanSupportTicket(db.Conn.QueryRow(
+		"SELECT "+supportTicketFields+" FROM support_tickets WHERE id=?", id,
+	).Scan)
+	if err != nil {
+		if isSQLErrNoRows(err) {
+			return nil, nil
+		}
+		return nil, errors.New("failed to get support ticket: " + err.Error())
+	}
+	return &t, nil
+}
+
+// returns nil when the user has no open or assigned tickets
+func (db *dbHandler) getActiveSupportTicket(pubkey string) (*supportTicket, error) {
+	t, err := scanSupportTicket(db.Conn.QueryRow(
+		"SELECT "+supportTicketFields+" FROM support_tickets WHERE pubkey=? AND status<>? "+
+			"ORDER BY id DESC LIMIT 1",
+		pubkey, supportTicketClosed,
+	).Scan)
+	if err != nil {
+		if isSQLErrNoRows(err) {
+			return nil, nil
+		}
+		return nil, errors.New("failed to get active support ticket: " + err.Error())
+	}
+	return &t, nil
+}
+
+func (db *dbHandler) getActiveSupportTickets(limit int) ([]supportTicket, error) {
+	rows, err := db.Conn.Query(
+		"SELECT "+supportTicketFields+" FROM support_tickets WHERE status<>? ORDER BY updated_at DESC LIMIT ?",
+		supportTicketClosed, limit,
+	)
+	if err != nil {
+		return nil, errors.New("failed to select support tickets: " + err.Error())
+	}
+	defer rows.Close()
+
+	tickets := []supportTicket{}
+	for rows.Next() {
+		t, err := scanSupportTicket(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		tickets = append(tickets, t)
+	}
+	return tickets, rows.Err()
+}
+
+// returns 0 if the telegram message is not in a ticket thread
+func (db *dbHandler) findSupportTicketByTgMessage(tgMessageID int) (int64, error) {
+	var ticketID int64
+	err := db.Conn.QueryRow(
+		"SELECT id FROM support_tickets WHERE tg_message_id=? "+
+			"UNION SELECT ticket_id FROM support_messages WHERE tg_message_id=? LIMIT 1",
+		tgMessageID, tgMessageID,
+	).Scan(&ticketID)
+	if err != nil {
+		if isSQLErrNoRows(err) {
+			return 0, nil
+		}
+		return 0, errors.New("failed to find support ticket: " + err.Error())
+	}
+	return ticketID, nil
+}
+
+func (db *dbHandler) saveSupportMessage(m supportMessage) error {
+	_, err := db.Conn.Exec(
+		"INSERT INTO support_messages SET ticket_id=?, is_moderator=?, author=?, text=?, "+
+			"tg_message_id=?, created_at=?",
+		m.TicketID, m.IsModerator, m.Author, m.Text, m.TgMessageID, time.Now().Unix(),
+	)
+	if err != nil {
+		return errors.New("failed to save support message: " + err.Error())
+	}
+	return nil
+}
+
+// returns the last messages in chronological order
+func (db *dbHandler) getSupportMessages(ticketID int64, limit int) ([]supportMessage, error) {
+	rows, err := db.Conn.Query(
+		"SELECT ticket_id,is_moderator,author,text,tg_message_id,created_at FROM "+
+			"(SELECT * FROM support_messages WHERE ticket_id=? ORDER BY id DESC LIMIT ?) last ORDER BY id",
+		ticketID, limit,
+	)
+	if err != nil {
+		return nil, errors.New("failed to select support messages: " + err.Error())
+	}
+	defer rows.Close()
+
+	messages := []supportMessage{}
+	for rows.Next() {
+		m := supportMessage{}
+		if err := rows.Scan(&m.TicketID, &m.IsModerator, &m.Author, &m.Text, &m.TgMessageID, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
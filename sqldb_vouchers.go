@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+func (db *dbHandler) saveVoucherBatch(batch *voucherBatch, codes []string) error {
+	tx, err := db.Conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		"INSERT INTO voucher_batches SET amount=?, codes_count=?, max_uses=?, per_user_limit=?, expires_at=?, created_at=?",
+		batch.Amount, len(codes), batch.MaxUses, batch.PerUserLimit, batch.ExpiresAt, batch.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	batch.ID, err = result.LastInsertId()
+	if err != nil {
+		return errors.New("failed to get batch ID: " + err.Error())
+	}
+	batch.CodesCount = len(codes)
+
+	for _, code := range codes {
+		if _, err := tx.Exec("INSERT INTO voucher_batch_codes SET code=?, batch_id=?", code, batch.ID); err != nil {
+			return fmt.Errorf("failed to save voucher code: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (db *dbHandler) getVoucherBatches(limit int) ([]voucherBatch, error) {
+	rows, err := db.Conn.Query(
+		"SELECT id,amount,codes_count,max_uses,per_user_limit,expires_at,created_at,revoked "+
+			"FROM voucher_batches ORDER BY id DESC LIMIT ?",
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	batches := []voucherBatch{}
+	for rows.Next() {
+		b := voucherBatch{}
+		if err := rows.Scan(
+			&b.ID, &b.Amount, &b.CodesCount, &b.MaxUses,
+			&b.PerUserLimit, &b.ExpiresAt, &b.CreatedAt, &b.Revoked,
+		); err != nil {
+			return nil, err
+		}
+		batches = append(batches, b)
+	}
+	return batches, rows.Err()
+}
+
+func (db *dbHandler) getVoucherBatch(batchID int64) (*voucherBatch, error) {
+	b := voucherBatch{}
+	err := db.Conn.QueryRow(
+		"SELECT id,amount,codes_count,max_uses,per_user_limit,expires_at,created_at,revoked "+
+			"FROM voucher_batches WHERE id=?",
+		batchID,
+	).Scan(
+		&b.ID, &b.Amount, &b.CodesCount, &b.MaxUses,
+		&b.PerUserLimit, &b.ExpiresAt, &b.CreatedAt, &b.Revoked,
+	)
+	if err != nil {
+		if isSQLErrNoRows(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (db *dbHandler) revokeVoucherBatch(batchID int64) error {
+	result, err := db.Conn.Exec("UPDATE voucher_batches SET revoked=1 WHERE id=?", batchID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return errors.New("failed to get rows affected count: " + err.Error())
+	}
+	if rowsAffected == 0 {
+		return errors.New("batch not found or already revoked")
+	}
+	return nil
+}
+
+// makes the code unusable by exhausting its uses
+func (db *dbHandler) revokeBatchVoucher(voucherCode string) error {
+	// rows affected is 0 for the exhausted code too, so the code is checked first
+	var batchID int64
+	err := db.Conn.QueryRow("SELECT batch_id FROM voucher_batch_codes WHERE code=?", voucherCode).Scan(&batchID)
+	if err != nil {
+		if isSQLErrNoRows(err) {
+			return errVoucherNotFound
+		}
+		return errors.New("failed to find voucher: " + err.Error())
+	}
+
+	_, err = db.Conn.Exec(
+		"UPDATE voucher_batch_codes c JOIN voucher_batches b ON b.id=c.batch_id "+
+			"SET c.uses=b.max_uses WHERE c.code=?",
+		voucherCode,
+	)
+	if err != nil {
+		return errors.New("failed to revoke voucher: " + err.Error())
+	}
+	return nil
+}
+
+func (db *dbHandler) getVoucherBatchStats(batchID int64) (*voucherBatchStats, error) {
+	stats := voucherBatchStats{}
+	err := db.Conn.QueryRow(
+		"SELECT COUNT(*), COUNT(DISTINCT pubkey), COALESCE(SUM(amount),0), COALESCE(MAX(created_at),0) "+
+			"FROM voucher_redemptions WHERE batch_id=?",
+		batchID,
+	).Scan(&stats.Redemptions, &stats.UniqueUsers, &stats.PointsIssued, &stats.LastRedemption)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Conn.QueryRow(
+		"SELECT COUNT(*) FROM voucher_batch_codes WHERE batch_id=? AND uses>0",
+		batchID,
+	).Scan(&stats.CodesUsed)
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// returns voucher amount or 0 when voucher not found.
+// budgetLeft is points left in the daily budget, -1 means no limit
+func (db *dbHandler) activateBatchVoucher(
+	ctx context.Context, userPubkey, voucherCode string, budgetLeft float64,
+) (float64, error) {
+	tx, err := db.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var uses int
+	b := voucherBatch{}
+	err = tx.QueryRowContext(
+		ctx,
+		"SELECT c.uses,b.id,b.amount,b.max_uses,b.per_user_limit,b.expires_at,b.revoked "+
+			"FROM voucher_batch_codes c JOIN voucher_batches b ON b.id=c.batch_id "+
+			"WHERE c.code=? FOR UPDATE",
+		voucherCode,
+	).Scan(&uses, &b.ID, &b.Amount, &b.MaxUses, &b.PerUserLimit, &b.ExpiresAt, &b.Revoked)
+	if err != nil {
+		if isSQLErrNoRows(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	if b.Revoked || uses >= b.MaxUses {
+		return 0, nil
+	}
+	if b.isExpired() {
+		return 0, errVoucherExpired
+	}
+	if budgetLeft >= 0 && b.Amount > budgetLeft {
+		return 0, errVoucherBudget
+	}
+
+	if b.PerUserLimit > 0 {
+		// other codes of the batch can be activated by the user at the same time,
+		// the batch row is locked until the redemption is saved
+		if _, err := tx.ExecContext(ctx, "SELECT id FROM voucher_batches WHERE id=? FOR UPDATE", b.ID); err != nil {
+			return 0, errors.New("failed to lock voucher batch: " + err.Error())
+		}
+
+		var userRedemptions int
+		err = tx.QueryRowContext(
+			ctx,
+			"SELECT COUNT(*) FROM voucher_redemptions WHERE batch_id=? AND pubkey=?",
+			b.ID, userPubkey,
+		).Scan(&userRedemptions)
+		if err != nil {
+			return 0, err
+		}
+		if userRedemptions >= b.PerUserLimit {
+			return 0, errVoucherUserLimit
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE voucher_batch_codes SET uses=uses+1 WHERE code=?", voucherCode); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(
+		ctx,
+		"INSERT INTO voucher_redemptions SET batch_id=?, code=?, pubkey=?, amount=?, created_at=?",
+		b.ID, voucherCode, userPubkey, b.Amount, time.Now().Unix(),
+	); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(
+		ctx,
+		"UPDATE "+db.UsersTable+" SET greed=greed+? WHERE pubkey=?",
+		b.Amount, userPubkey,
+	); err != nil {
+		return 0, err
+	}
+	if err := db.insertPointsHistory(ctx, tx, userPubkey, pointsKindVoucher, b.Amount); err != nil {
+		return 0, err
+	}
+
+	return b.Amount, tx.Commit()
+}
+
+// returns voucher amount or 0 when voucher not found.
+// the code is deleted first, so only one user can activate it.
+// budgetLeft is points left in the daily budget, -1 means no limit
+func (db *dbHandler) activateGameVoucher(
+	ctx context.Context, userPubkey, voucherCode string, budgetLeft float64,
+) (float64, error) {
+	tx, err := db.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var amount float64
+	err = tx.QueryRowContext(ctx, "SELECT amount FROM game_vouchers WHERE code=? FOR UPDATE", voucherCode).Scan(&amount)
+	if err != nil {
+		if isSQLErrNoRows(err) {
+			return 0, nil
+		}
+		return 0, errors.New("failed to select voucher: " + err.Error())
+	}
+	if budgetLeft >= 0 && amount > budgetLeft {
+		return 0, errVoucherBudget
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM game_vouchers WHERE code=?", voucherCode)
+	if err != nil {
+		return 0, errors.New("failed to delete voucher: " + err.Error())
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, errors.New("failed to get rows affected count: " + err.Error())
+	}
+	if rowsAffected != 1 {
+		return 0, nil // activated by another user
+	}
+
+	result, err = tx.ExecContext(ctx, "UPDATE "+db.UsersTable+" SET greed=greed+? WHERE pubkey=?", amount, userPubkey)
+	if err != nil {
+		return 0, errors.New("failed to add voucher points: " + err.Error())
+	}
+	rowsAffected, err = result.RowsAffected()
+	if err != nil {
+		return 0, errors.New("failed to get rows affected count: " + err.Error())
+	}
+	if rowsAffected == 0 {
+		return 0, errors.New("failed to add voucher points: user " + userPubkey + " not found")
+	}
+	if err := db.insertPointsHistory(ctx, tx, userPubkey, pointsKindVoucher, amount); err != nil {
+		return 0, err
+	}
+
+	return amount, tx.Commit()
+}
+
+func (db *dbHandler) getVoucherAttempts(pubkey string) (*voucherAttempts, error) {
+	a := voucherAttempts{Pubkey: pubkey}
+	err := db.Conn.QueryRow(
+		"SELECT failed,lockouts,locked_until,last_failure FROM voucher_attempts WHERE pubkey=?",
+		pubkey,
+	).Scan(&a.Failed, &a.Lockouts, &a.LockedUntil, &a.LastFailure)
+	if err != nil && !isSQLErrNoRows(err) {
+		return nil, errors.New("failed to select voucher attempts: " + err.Error())
+	}
+	return &a, nil
+}
+
+func (db *dbHandler) saveVoucherAttempts(a *voucherAttempts) error {
+	_, err := db.Conn.Exec(
+		"INSERT INTO voucher_attempts SET pubkey=?, failed=?, lockouts=?, locked_until=?, last_failure=? "+
+			"ON DUPLICATE KEY UPDATE failed=VALUES(failed), lockouts=VALUES(lockouts), "+
+			"locked_until=VALUES(locked_until), last_failure=VALUES(last_failure)",
+		a.Pubkey, a.Failed, a.Lockouts, a.LockedUntil, a.LastFailure,
+	)
+	if err != nil {
+		return errors.New("failed to save voucher attempts: " + err.Error())
+	}
+	return nil
+}
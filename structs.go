@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"net/http"
 	"sync"
 	"time"
 
@@ -17,20 +19,47 @@ type solution struct {
 	Config                    config
 	WsHandlers                map[string]wsHandler
 	ContactsOnlineCache       []utopiago.ContactData
-	ChannelOnlineCache        []utopiago.ChannelContactData
+	ChannelsOnlineCache       channelsOnline
 	WithdrawNotifyRateLimiter *rate.RateLimiter
 
 	HandleContactsCron   *simplecron.CronObject
 	VouchersGiveawayCron *simplecron.CronObject
-	VouchersCooldown     map[string]time.Time // pubkey -> last time voucher activated
+	HealthCheckCron      *simplecron.CronObject
+	VoucherFormat        *voucherFormat
+	Giveaways            vouchersGiveaway
+	Fraud                *fraudDetector
+	Restrictions         restrictionsCache
+	Languages            languagesCache
+	Memberships          membershipCache
+	Rewards              *rewardSchedule
+	Budget               dailyBudget
+	FAQ                  faqBase
+	UserCommands         map[string]*userCommand // alias -> command
+	UserCommandsList     []*userCommand
 
 	IsContactsCheckInProgress bool
+	ContactsCheckLock         sync.Mutex // contacts check runs in cron goroutine
 	UsersOnline               map[string]*onlineData
+	UsersOnlineLock           sync.RWMutex
 	UtopiaModerators          map[string]struct{} // pubkey -> empty struct
 	TelegramModerators        map[int64]struct{}  // telegram ID -> empty struct
 
-	MessageHandler   messagesHandler
-	TelegramHandlers []handlerPair
+	MessageHandler    messagesHandler
+	TelegramHandlers  []handlerPair
+	AdminAPIServer    *http.Server
+	MonitoringServer  *http.Server
+	Health            *healthState
+	Reconnect         *reconnectSupervisor
+	Outbound          *outboundQueue
+	OutboundWakeup    chan struct{}
+	InboundLimiter    *inboundLimiter
+	Events            *eventsPool
+	NLU               nluChain
+	OutboundDone      chan struct{} // closed when queue worker is stopped
+	Ctx               context.Context
+	cancelCtx         context.CancelFunc
+	ShutdownCh        chan struct{} // closed on shutdown
+	IsUtopiaConnected bool          // at least once
 }
 
 type messagesHandler struct {
@@ -40,8 +69,7 @@ type messagesHandler struct {
 }
 
 type onlineData struct {
-	Pubkey         string
-	NotifySentOnce bool
+	Pubkey string
 }
 
 type config struct {
@@ -58,7 +86,8 @@ type config struct {
 	UseIntervals             bool                  `json:"use_intervals"`
 	Intervals                []pointsInterval      `json:"intervals"`
 	ContactsCronPerMinute    int                   `json:"per_minute_cron"`
-	ChannelID                string                `json:"channel"`
+	ChannelID                string                `json:"channel"` // main channel, default: first of `channels`
+	Channels                 []channelConfig       `json:"channels"`
 	RequestsModeratorPubkey  string                `json:"requests_moderator_pubkey"`
 	DialogflowProjectID      string                `json:"dialogflow_project_id"`
 	DialogflowLandcode       string                `json:"dialogflow_langcode"`
@@ -74,6 +103,130 @@ type config struct {
 	Tips                     []string              `json:"tips"`
 	CoinsWithdrawLabel       string                `json:"coins_withdraw_label"`
 	GameVoucherPrefix        string                `json:"game_voucher_prefix"`
+	GameVoucherFormat        string                `json:"game_voucher_format"`
+	VouchersGiveaway         giveawayConfig        `json:"vouchers_giveaway"`
+	AntiFraud                antiFraudConfig       `json:"antifraud"`
+	BannedMessage            string                `json:"banned_message"`
+	AdminAPI                 adminAPIConfig        `json:"admin_api"`
+	Monitoring               monitoringConfig      `json:"monitoring"`
+	Restart                  restartConfig         `json:"restart"`
+	Reconnect                reconnectConfig       `json:"reconnect"`
+	InboundLimit             inboundLimitConfig    `json:"inbound_limit"`
+	EventsPool               eventsPoolConfig      `json:"events_pool"`
+	ShutdownTimeoutSeconds   int                   `json:"shutdown_timeout_seconds"`
+	LocalesDir               string                `json:"locales_dir"`      // default: locales
+	DefaultLanguage          string                `json:"default_language"` // default: ru
+	NLU                      nluConfig             `json:"nlu"`
+	FAQMinScore              float64               `json:"faq_min_score"` // 0..1, default: 0.75
+	ChannelReminder          channelReminderConfig `json:"channel_reminder"`
+	RewardsTimezone          string                `json:"rewards_timezone"` // for reward rules, default: Local
+	RewardRules              []rewardRule          `json:"reward_rules"`
+	Budget                   budgetConfig          `json:"budget"`
+}
+
+type budgetConfig struct {
+	DailyPoints     float64 `json:"daily_points"`      // all users, vouchers included. 0 - no limit
+	UserDailyPoints float64 `json:"user_daily_points"` // accrual for online, 0 - no limit
+	Mode            string  `json:"mode"`              // pause or prorate, default: pause
+}
+
+// changes channel points rate by time: rate * multiplier + bonus
+type rewardRule struct {
+	Name       string   `json:"name"`
+	Channels   []string `json:"channels"`   // IDs or names, empty for all channels
+	StartDate  string   `json:"start_date"` // 2006-01-02, empty for no limit
+	EndDate    string   `json:"end_date"`   // inclusive
+	Weekdays   []int    `json:"weekdays"`   // 1 - monday .. 7 - sunday, empty for all days
+	FromHour   int      `json:"from_hour"`  // 0..23
+	ToHour     int      `json:"to_hour"`    // exclusive, can be less than from_hour. equal hours - all day
+	Multiplier float64  `json:"multiplier"` // default: 1
+	Bonus      float64  `json:"bonus"`      // points per 24h
+	Timezone   string   `json:"timezone"`   // default: rewards_timezone
+}
+
+type channelReminderConfig struct {
+	Disabled      bool `json:"disabled"`
+	IntervalHours int  `json:"interval_hours"` // between reminders, default: 24
+	MaxCount      int  `json:"max_count"`      // until the user joins, default: 3
+	ResetDays     int  `json:"reset_days"`     // reminders count is reset after, default: 30
+}
+
+type nluConfig struct {
+	Backends            []string    `json:"backends"`  // local, dialogflow. asked in order
+	MinScore            float64     `json:"min_score"` // for local intents, 0..1
+	Intents             []nluIntent `json:"intents"`
+	DialogflowProjectID string      `json:"dialogflow_project_id"` // default: dialogflow_project_id
+	DialogflowLangcode  string      `json:"dialogflow_langcode"`   // default: dialogflow_langcode
+}
+
+type nluIntent struct {
+	Name     string            `json:"name"`
+	Keywords []string          `json:"keywords"`
+	Phrases  []string          `json:"phrases"`
+	Answers  map[string]string `json:"answers"` // lang -> answer
+}
+
+type eventsPoolConfig struct {
+	Workers               int `json:"workers"`
+	QueueSize             int `json:"queue_size"` // per worker
+	HandlerTimeoutSeconds int `json:"handler_timeout_seconds"`
+	ReorderWindowMs       int `json:"reorder_window_ms"` // how long an event waits for the earlier ones
+}
+
+type inboundLimitConfig struct {
+	Enabled            bool `json:"enabled"`
+	MaxMessages        int  `json:"max_messages"` // per window
+	WindowSeconds      int  `json:"window_seconds"`
+	MuteMinutes        int  `json:"mute_minutes"`
+	EscalateAfterMutes int  `json:"escalate_after_mutes"` // per 24h
+}
+
+type reconnectConfig struct {
+	MinBackoffSeconds    int `json:"min_backoff_seconds"`
+	MaxBackoffSeconds    int `json:"max_backoff_seconds"`
+	AttemptsBeforeReboot int `json:"attempts_before_reboot"`
+	MaxReboots           int `json:"max_reboots"` // per window
+	RebootsWindowMinutes int `json:"reboots_window_minutes"`
+}
+
+type restartConfig struct {
+	Strategy     string            `json:"strategy"`      // systemd, docker, command or noop
+	Targets      map[string]string `json:"targets"`       // service -> systemd unit or docker container
+	DockerSocket string            `json:"docker_socket"` // default: /var/run/docker.sock
+	Commands     map[string]string `json:"commands"`      // service -> command for command strategy
+}
+
+type monitoringConfig struct {
+	Enabled bool   `json:"enabled"`
+	Listen  string `json:"listen"` // example: 127.0.0.1:9090
+}
+
+type adminAPIConfig struct {
+	Enabled bool   `json:"enabled"`
+	Listen  string `json:"listen"` // example: 127.0.0.1:8090
+	Token   string `json:"token"`
+}
+
+type antiFraudConfig struct {
+	Enabled   bool    `json:"enabled"`
+	FlagScore float64 `json:"flag_score"`
+}
+
+type giveawayConfig struct {
+	Enabled         bool    `json:"enabled"`
+	IntervalMinutes int     `json:"interval_minutes"`
+	ExpireMinutes   int     `json:"expire_minutes"` // unclaimed voucher is deleted after it
+	MinAmount       float64 `json:"min_amount"`
+	MaxAmount       float64 `json:"max_amount"`
+}
+
+type channelConfig struct {
+	ID           string           `json:"id"`
+	Name         string           `json:"name"`
+	Required     bool             `json:"required"` // no points without the channel, otherwise it gives a bonus
+	PointsPer24h float64          `json:"points_per_24h"`
+	UseIntervals bool             `json:"use_intervals"`
+	Intervals    []pointsInterval `json:"intervals"`
 }
 
 type pointsInterval struct {
@@ -85,6 +238,7 @@ type pointsInterval struct {
 type dbHandler struct {
 	Conn       *sql.DB
 	UsersTable string
+	Location   *time.Location // for days in history & stats, same as rewards timezone
 }
 
 type dbConnectionTask struct {
@@ -97,10 +251,10 @@ type dbConnectionTask struct {
 }
 
 type userData struct {
-	UID      string
-	Pubkey   string
-	NickName string
-	Balance  float64
+	UID      string  `json:"uid"`
+	Pubkey   string  `json:"pubkey"`
+	NickName string  `json:"nickname"`
+	Balance  float64 `json:"balance"`
 }
 
 type handlerPair struct {
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	tb "github.com/Sagleft/telegobot"
+	"github.com/google/logger"
+)
+
+const (
+	supportTicketOpen     = 1 // waiting for moderator
+	supportTicketAssigned = 2
+	supportTicketClosed   = 3
+)
+
+type supportTicket struct {
+	ID           int64
+	Pubkey       string
+	Nick         string
+	Status       int
+	AssigneeID   int64 // telegram ID
+	AssigneeName string
+	TgMessageID  int // thread header in moderators chat
+	CreatedAt    int64
+	UpdatedAt    int64
+	ClosedAt     int64
+}
+
+type supportMessage struct {
+	TicketID    int64
+	IsModerator bool
+	Author      string
+	Text        string
+	TgMessageID int
+	CreatedAt   int64
+}
+
+func getSupportTicketStatusName(lang string, status int) string {
+	switch status {
+	default:
+		return strconv.Itoa(status)
+	case supportTicketOpen:
+		return tr(lang, "support.status_open")
+	case supportTicketAssigned:
+		return tr(lang, "support.status_assigned")
+	case supportTicketClosed:
+		return tr(lang, "support.status_closed")
+	}
+}
+
+func (t *supportTicket) isActive() bool {
+	return t.Status == supportTicketOpen || t.Status == supportTicketAssigned
+}
+
+// returns false when the ticket is already assigned to the moderator
+func (t *supportTicket) assign(moderatorID int64, moderatorName string) bool {
+	if t.Status == supportTicketAssigned && t.AssigneeID == moderatorID {
+		return false
+	}
+
+	t.Status = supportTicketAssigned
+	t.AssigneeID = moderatorID
+	t.AssigneeName = moderatorName
+	return true
+}
+
+// tickets are relayed to the telegram moderators chat
+func (app *solution) isSupportEnabled() bool {
+	return app.Config.TelegramModeratorsChat != 0 && app.TelegramBot != nil
+}
+
+// returns nil if the user has no open tickets
+func (app *solution) getActiveSupportTicket(pubkey string) (*supportTicket, error) {
+	if !app.isSupportEnabled() {
+		return nil, nil
+	}
+	return app.DB.getActiveSupportTicket(pubkey)
+}
+
+func getTelegramUserName(user *tb.User) string {
+	if user.Username != "" {
+		return "@" + user.Username
+	}
+	return strings.TrimSpace(user.FirstName + " " + user.LastName)
+}
+
+// returns message ID in the moderators chat
+func (app *solution) sendToSupportThread(ticket *supportTicket, text string) (int, error) {
+	options := &tb.SendOptions{}
+	if ticket.TgMessageID != 0 {
+		options.ReplyTo = &tb.Message{ID: ticket.TgMessageID}
+	}
+
+	msg, err := app.TelegramBot.Send(tb.ChatID(app.Config.TelegramModeratorsChat), text, options)
+	if err != nil {
+		return 0, errors.New("failed to send message to support thread: " + err.Error())
+	}
+	return msg.ID, nil
+}
+
+// returns reply to the manager command
+func (app *solution) handleManagerCommand(pubkey, nick, lang string) string {
+	if !app.isSupportEnabled() {
+		return tr(lang, "user.manager", app.Config.RequestsModeratorPubkey, app.Config.ModeratorTelegram)
+	}
+	return app.openSupportTicket(pubkey, nick, lang, "")
+}
+
+// first message is posted to the ticket thread, can be empty
+func (app *solution) openSupportTicket(pubkey, nick, lang, firstMessage string) string {
+	ticket := &supportTicket{
+		Pubkey: pubkey,
+		Nick:   nick,
+		Status: supportTicketOpen,
+	}
+	if err := app.DB.saveSupportTicket(ticket); err != nil {
+		logger.Error(err)
+		return tr(lang, "user.request_error")
+	}
+
+	var err error
+	ticket.TgMessageID, err = app.sendToSupportThread(ticket, tr(catalog.DefaultLang, "support.ticket_header",
+		ticket.ID, nick, pubkey, lang,
+	))
+	if err != nil {
+		logger.Error(err)
+		// moderators don't see the ticket, don't keep the user in it
+		ticket.Status = supportTicketClosed
+		if err := app.DB.saveSupportTicket(ticket); err != nil {
+			logger.Error(err)
+		}
+		return tr(lang, "user.request_error")
+	}
+	if err := app.DB.saveSupportTicket(ticket); err != nil {
+		logger.Error(err)
+	}
+
+	if firstMessage != "" {
+		if _, err := app.sendToSupportThread(ticket, firstMessage); err != nil {
+			logger.Error(err)
+		}
+	}
+
+	logger.Info("support ticket " + strconv.FormatInt(ticket.ID, 10) + " opened by " + pubkey)
+	return tr(lang, "user.ticket_opened", ticket.ID)
+}
+
+// user messages go to the ticket thread until the ticket is closed
+func (app *solution) handleSupportUserMessage(ticket *supportTicket, lang, messageText string) {
+	switch strings.ToLower(strings.TrimSpace(messageText)) {
+	case comandClose, comandClose2:
+		if err := app.closeSupportTicket(ticket, ticket.Nick); err != nil {
+			logger.Error(err)
+		}
+		return
+	}
+
+	tgMessageID, err := app.sendToSupportThread(ticket, tr(catalog.DefaultLang, "support.user_message",
+		ticket.ID, ticket.Nick, messageText,
+	))
+	if err != nil {
+		logger.Error(err)
+		if err := app.sendMessage(ticket.Pubkey, tr(lang, "user.request_failed")); err != nil {
+			app.onUtopiaError(err)
+		}
+		return
+	}
+
+	err = app.DB.saveSupportMessage(supportMessage{
+		TicketID:    ticket.ID,
+		Author:      ticket.Nick,
+		Text:        messageText,
+		TgMessageID: tgMessageID,
+	})
+	if err != nil {
+		logger.Error(err)
+	}
+}
+
+func (app *solution) closeSupportTicket(ticket *supportTicket, closedBy string) error {
+	ticket.Status = supportTicketClosed
+	if err := app.DB.saveSupportTicket(ticket); err != nil {
+		return err
+	}
+
+	lang, _ := app.getUserLanguage(ticket.Pubkey)
+	if err := app.sendMessageWithPriority(ticket.Pubkey, tr(lang, "user.ticket_closed", ticket.ID), messagePriorityHigh); err != nil {
+		logger.Error(err)
+	}
+
+	_, err := app.sendToSupportThread(ticket, tr(catalog.DefaultLang, "support.ticket_closed", ticket.ID, closedBy))
+	return err
+}
+
+func (app *solution) assignSupportTicket(ticket *supportTicket, moderator *tb.User) error {
+	if !ticket.assign(moderator.ID, getTelegramUserName(moderator)) {
+		return nil
+	}
+	if err := app.DB.saveSupportTicket(ticket); err != nil {
+		return err
+	}
+
+	_, err := app.sendToSupportThread(ticket, tr(catalog.DefaultLang, "support.ticket_assigned",
+		ticket.ID, ticket.AssigneeName,
+	))
+	return err
+}
+
+// handles moderator reply in the ticket thread. returns false if the message is not a ticket reply
+func (app *solution) handleSupportReply(m *tb.Message) bool {
+	if !app.isSupportEnabled() || m.Chat == nil || m.Chat.ID != app.Config.TelegramModeratorsChat || m.ReplyTo == nil {
+		return false
+	}
+
+	ticketID, err := app.DB.findSupportTicketByTgMessage(m.ReplyTo.ID)
+	if err != nil {
+		logger.Error(err)
+		return false
+	}
+	if ticketID == 0 {
+		return false
+	}
+
+	if err := app.handleSupportTicketReply(ticketID, m); err != nil {
+		logger.Error(err)
+		if _, tgErr := app.TelegramBot.Reply(m, "ERROR: "+err.Error()); tgErr != nil {
+			logger.Error(tgErr)
+		}
+	}
+	return true
+}
+
+func (app *solution) handleSupportTicketReply(ticketID int64, m *tb.Message) error {
+	ticket, err := app.DB.getSupportTicket(ticketID)
+	if err != nil {
+		return err
+	}
+	if ticket == nil || !ticket.isActive() {
+		return errors.New("ticket is closed")
+	}
+
+	moderatorName := getTelegramUserName(m.Sender)
+	text := strings.TrimSpace(m.Text)
+	switch strings.ToLower(text) {
+	case comandClose, comandClose2:
+		return app.closeSupportTicket(ticket, moderatorName)
+	case comandTake, comandTake2:
+		return app.assignSupportTicket(ticket, m.Sender)
+	}
+
+	if ticket.Status == supportTicketOpen {
+		if err := app.assignSupportTicket(ticket, m.Sender); err != nil {
+			return err
+		}
+	}
+
+	lang, _ := app.getUserLanguage(ticket.Pubkey)
+	if err := app.sendMessageWithPriority(ticket.Pubkey, tr(lang, "user.ticket_reply", text), messagePriorityHigh); err != nil {
+		return err
+	}
+
+	return app.DB.saveSupportMessage(supportMessage{
+		TicketID:    ticket.ID,
+		IsModerator: true,
+		Author:      moderatorName,
+		Text:        text,
+		TgMessageID: m.ID,
+	})
+}
+
+func (app *solution) handleSupportTicketsList(lang string) ([]string, error) {
+	tickets, err := app.DB.getActiveSupportTickets(supportTicketsListLimit)
+	if err != nil {
+		return nil, err
+	}
+	if len(tickets) == 0 {
+		return []string{tr(lang, "mod.tickets_empty")}, nil
+	}
+
+	msg := tr(lang, "mod.tickets_title")
+	for _, t := range tickets {
+		status := getSupportTicketStatusName(lang, t.Status)
+		if t.Status == supportTicketAssigned {
+			status += ": " + t.AssigneeName
+		}
+		msg += tr(lang, "mod.tickets_item", t.ID, status, t.Nick, t.Pubkey, formatUnixTime(t.UpdatedAt))
+	}
+	return []string{msg}, nil
+}
+
+func parseSupportTicketID(raw string) (int64, error) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(raw, "#"), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, errors.New("invalid ticket number: " + raw)
+	}
+	return id, nil
+}
+
+func (app *solution) handleSupportTicketHistory(lang, ticketIDRaw string) ([]string, error) {
+	ticketID, err := parseSupportTicketID(ticketIDRaw)
+	if err != nil {
+		return nil, err
+	}
+
+	ticket, err := app.DB.getSupportTicket(ticketID)
+	if err != nil {
+		return nil, err
+	}
+	if ticket == nil {
+		return []string{tr(lang, "mod.ticket_not_found")}, nil
+	}
+
+	messages, err := app.DB.getSupportMessages(ticketID, supportHistoryLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := tr(lang, "mod.ticket_view",
+		ticket.ID, ticket.Nick, ticket.Pubkey, getSupportTicketStatusName(lang, ticket.Status),
+		ticket.AssigneeName, formatUnixTime(ticket.CreatedAt),
+	)
+	for _, m := range messages {
+		author := m.Author
+		if m.IsModerator {
+			author = "👤 " + author
+		}
+		msg += "\n\n" + formatUnixTime(m.CreatedAt) + " " + author + ":\n" + m.Text
+	}
+	return []string{msg}, nil
+}
+
+func (app *solution) handleSupportTicketClose(lang, ticketIDRaw string, telegramUserID int64) ([]string, error) {
+	ticketID, err := parseSupportTicketID(ticketIDRaw)
+	if err != nil {
+		return nil, err
+	}
+
+	ticket, err := app.DB.getSupportTicket(ticketID)
+	if err != nil {
+		return nil, err
+	}
+	if ticket == nil || !ticket.isActive() {
+		return []string{tr(lang, "mod.ticket_not_found")}, nil
+	}
+
+	closedBy := tr(catalog.DefaultLang, "support.moderator")
+	if telegramUserID != 0 {
+		closedBy += " " + strconv.FormatInt(telegramUserID, 10)
+	}
+	if err := app.closeSupportTicket(ticket, closedBy); err != nil {
+		return nil, err
+	}
+	return []string{tr(lang, "mod.ticket_closed", ticket.ID)}, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import "testing"
+
+func TestSupportTicketStatus(t *testing.T) {
+	ticket := supportTicket{Status: supportTicketOpen}
+	if !ticket.isActive() {
+		t.Fatal("open ticket must be active")
+	}
+
+	if !ticket.assign(1, "@first") {
+		t.Fatal("expected open ticket to be assigned")
+	}
+	if ticket.Status != supportTicketAssigned || ticket.AssigneeID != 1 || ticket.AssigneeName != "@first" {
+		t.Fatalf("unexpected ticket after assign: %+v", ticket)
+	}
+	if ticket.assign(1, "@first") {
+		t.Fatal("ticket is already assigned to the moderator")
+	}
+	if !ticket.isActive() {
+		t.Fatal("assigned ticket must be active")
+	}
+
+	// other moderator takes the ticket
+	if !ticket.assign(2, "@second") {
+		t.Fatal("expected ticket to be reassigned")
+	}
+	if ticket.AssigneeID != 2 || ticket.AssigneeName != "@second" {
+		t.Fatalf("unexpected ticket after reassign: %+v", ticket)
+	}
+
+	ticket.Status = supportTicketClosed
+	if ticket.isActive() {
+		t.Fatal("closed ticket must not be active")
+	}
+}
+
+func TestParseSupportTicketID(t *testing.T) {
+	cases := map[string]int64{
+		"12":  12,
+		"#12": 12,
+		"0":   0,
+		"-3":  0,
+		"#":   0,
+		"abc": 0,
+		"":    0,
+	}
+	for raw, expected := range cases {
+		id, err := parseSupportTicketID(raw)
+		if expected == 0 {
+			if err == nil {
+				t.Fatalf("%q: expected error, got %v", raw, id)
+			}
+			continue
+		}
+		if err != nil || id != expected {
+			t.Fatalf("%q: expected %v, got %v, %v", raw, expected, id, err)
+		}
+	}
+}
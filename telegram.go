@@ -3,11 +3,11 @@ package main
 import (
 	"bytes"
 	"log"
-	"os/exec"
 	"strconv"
 	"time"
 
 	tb "github.com/Sagleft/telegobot"
+	utopiago "github.com/Sagleft/utopialib-go"
 	"github.com/google/logger"
 )
 
@@ -56,10 +56,17 @@ func (app *solution) runTelegramBot() error {
 	}
 	app.setupHandlers(app.TelegramHandlers)
 
-	go app.TelegramBot.Start()
+	go app.runTelegramPoller()
 	return nil
 }
 
+func (app *solution) runTelegramPoller() {
+	app.Health.setTgPollerRunning(true)
+	defer app.Health.setTgPollerRunning(false)
+
+	app.TelegramBot.Start()
+}
+
 func (app *solution) getOnlineCount(m *tb.Message) {
 	if !app.checkTelegramAccess(m) {
 		return
@@ -71,10 +78,10 @@ func (app *solution) getOnlineCount(m *tb.Message) {
 		return
 	}
 
-	msg := "Всего контактов: " + strconv.Itoa(contactsData.Contacts) + "\n"
-	msg += "Контактов онлайн: " + strconv.Itoa(contactsData.ContactsOnline) + "\n"
-	msg += "Онлайн в канале: " + strconv.Itoa(contactsData.ChannelOnline) + "\n"
-	msg += "Контактов онлайн в канале: " + strconv.Itoa(contactsData.ContactsInChannel)
+	msg := tr(getTelegramLanguage(m.Sender.LanguageCode), "tg.online_count",
+		contactsData.Contacts, contactsData.ContactsOnline,
+		contactsData.ChannelOnline, contactsData.ContactsInChannel,
+	)
 
 	app.TelegramBot.Send(m.Sender, msg)
 }
@@ -108,15 +115,20 @@ func (app *solution) getContactsData() (*getContactsResult, error) {
 		return nil, err
 	}
 
-	channelOnline, err := app.getChannelOnline()
+	channels, err := app.getChannelsOnline()
 	if err != nil {
 		return nil, err
 	}
-	channelOnlineMap := app.getChannelOnlineMap(channelOnline)
+	return app.buildContactsData(contacts, channels), nil
+}
 
+func (app *solution) buildContactsData(
+	contacts []utopiago.ContactData,
+	channels channelsOnline,
+) *getContactsResult {
 	result := getContactsResult{
 		Contacts:      len(contacts),
-		ChannelOnline: len(channelOnlineMap),
+		ChannelOnline: channels.countMembers(),
 	}
 	result.CSV = "nick, pubkey, online, online in channel"
 	for _, contact := range contacts {
@@ -129,8 +141,7 @@ func (app *solution) getContactsData() (*getContactsResult, error) {
 			result.CSV += ", -"
 		}
 
-		_, isOnlineInChannel := channelOnlineMap[contact.Nick]
-		if isOnlineInChannel {
+		if channels.isEligible(contact.Nick) {
 			result.CSV += ", +"
 			result.ContactsInChannel++
 		} else {
@@ -138,7 +149,8 @@ func (app *solution) getContactsData() (*getContactsResult, error) {
 		}
 	}
 
-	return &result, nil
+	botMetrics.setContactsData(&result)
+	return &result
 }
 
 func (app *solution) returnErrorToSender(m *tb.Message, err error) {
@@ -185,7 +197,7 @@ func (app *solution) checkTelegramAccess(m *tb.Message) bool {
 
 func (app *solution) checkRebootsFeatureDisabled(m *tb.Message) bool {
 	if app.Config.RebootsByUserDisabled {
-		_, err := app.TelegramBot.Send(m.Sender, "фича отключена")
+		_, err := app.TelegramBot.Send(m.Sender, tr(getTelegramLanguage(m.Sender.LanguageCode), "tg.feature_disabled"))
 		if err != nil {
 			logger.Error(err)
 			return true
@@ -203,10 +215,7 @@ func (app *solution) handleReboot(m *tb.Message) {
 		return
 	}
 
-	_, err := app.TelegramBot.Send(
-		m.Sender,
-		"А может не надо? Может лучше через /restartbot ? или /restartutopia ?\n\nНу а если всё совсем плохо...\n/confirmreboot",
-	)
+	_, err := app.TelegramBot.Send(m.Sender, tr(getTelegramLanguage(m.Sender.LanguageCode), "tg.reboot_confirm"))
 	if err != nil {
 		logger.Error(err)
 		return
@@ -222,18 +231,18 @@ func (app *solution) confirmHandleReboot(m *tb.Message) {
 		return
 	}
 
-	_, err := app.TelegramBot.Send(m.Sender, "Сервер будет перезагружен через 3 секунды..")
+	lang := getTelegramLanguage(m.Sender.LanguageCode)
+	_, err := app.TelegramBot.Send(m.Sender, tr(lang, "tg.server_reboot"))
 	if err != nil {
 		logger.Error(err)
 		return
 	}
 
 	time.Sleep(time.Second * 3)
-	r := exec.Command("reboot")
-	err = r.Run()
+	err = restarter.Restart(restartServiceServer)
 	if err != nil {
 		logger.Error(err)
-		app.TelegramBot.Send(m.Sender, "Не удалось заребутить: "+err.Error())
+		app.TelegramBot.Send(m.Sender, tr(lang, "tg.reboot_failed", err.Error()))
 	}
 }
 
@@ -246,7 +255,8 @@ func (app *solution) handleRestartUtopia(m *tb.Message) {
 		return
 	}
 
-	_, err := app.TelegramBot.Send(m.Sender, "U и бот будут перезагружены через 3 секунды..")
+	lang := getTelegramLanguage(m.Sender.LanguageCode)
+	_, err := app.TelegramBot.Send(m.Sender, tr(lang, "tg.utopia_restart"))
 	if err != nil {
 		logger.Error(err)
 		return
@@ -254,7 +264,7 @@ func (app *solution) handleRestartUtopia(m *tb.Message) {
 
 	err = doUtopiaReboot()
 	if err != nil {
-		app.TelegramBot.Send(m.Sender, "Не удалось перезапустить: "+err.Error())
+		app.TelegramBot.Send(m.Sender, tr(lang, "tg.restart_failed", err.Error()))
 	}
 }
 
@@ -267,18 +277,18 @@ func (app *solution) handleRestartBot(m *tb.Message) {
 		return
 	}
 
-	_, err := app.TelegramBot.Send(m.Sender, "Бот будет перезагружен через 3 секунды..")
+	lang := getTelegramLanguage(m.Sender.LanguageCode)
+	_, err := app.TelegramBot.Send(m.Sender, tr(lang, "tg.bot_restart"))
 	if err != nil {
 		logger.Error(err)
 		return
 	}
 
 	time.Sleep(time.Second * 3)
-	r := exec.Command("/usr/bin/systemctl", "restart", "bankbot")
-	err = r.Run()
+	err = restarter.Restart(restartServiceBot)
 	if err != nil {
 		logger.Error(err)
-		app.TelegramBot.Send(m.Sender, "Не удалось перезапустить: "+err.Error())
+		app.TelegramBot.Send(m.Sender, tr(lang, "tg.restart_failed", err.Error()))
 	}
 }
 
@@ -287,7 +297,12 @@ func (app *solution) handleTextRequest(m *tb.Message) {
 		return
 	}
 
-	messages, err := app.handleModeratorRequest(m.Text, true, m.Sender.ID)
+	if app.handleSupportReply(m) {
+		return
+	}
+
+	lang := getTelegramLanguage(m.Sender.LanguageCode)
+	messages, err := app.handleModeratorRequest(app.Ctx, lang, m.Text, true, m.Sender.ID)
 	if err != nil {
 		_, tgErr := app.TelegramBot.Send(m.Sender, "ERROR: "+err.Error())
 		if tgErr != nil {
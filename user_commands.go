@@ -0,0 +1,226 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/logger"
+)
+
+type userCommandTask struct {
+	Pubkey string
+	Nick   string
+	Lang   string
+	User   *userData // nil for moderators
+	Args   []string
+}
+
+type userCommand struct {
+	Name    string              // description key: cmd.<name>
+	Aliases map[string][]string // lang -> aliases
+	Handler func(task userCommandTask) (string, error)
+	HasArgs bool // commands without args match the whole message only
+
+	// available for moderators too, handled before moderator commands
+	IsForModerators bool
+}
+
+func (app *solution) setupUserCommands() error {
+	app.UserCommandsList = []*userCommand{
+		{
+			Name:    "help",
+			Aliases: map[string][]string{"ru": {"помощь", "команды"}, "en": {"help", "commands"}},
+			Handler: app.handleHelpCommand,
+		},
+		{
+			Name:    "balance",
+			Aliases: map[string][]string{"ru": {"баланс"}, "en": {"balance"}},
+			Handler: app.handleBalanceCommand,
+		},
+		{
+			Name:    "history",
+			Aliases: map[string][]string{"ru": {"история"}, "en": {"history"}},
+			Handler: app.handleHistoryCommand,
+		},
+		{
+			Name:    "stats",
+			Aliases: map[string][]string{"ru": {"статистика", "стата"}, "en": {"stats", "statistics"}},
+			Handler: app.handleStatsCommand,
+		},
+		{
+			Name:    "withdraw",
+			Aliases: map[string][]string{"ru": {"вывод", "вывести"}, "en": {"withdraw"}},
+			Handler: app.handleWithdrawCommand,
+		},
+		{
+			Name:    "rank",
+			Aliases: map[string][]string{"ru": {"рейтинг", "ранг", "топ"}, "en": {"rank", "top"}},
+			Handler: app.handleRankCommand,
+		},
+		{
+			Name:    "manager",
+			Aliases: map[string][]string{"ru": {"менеджер", "поддержка"}, "en": {"manager", "support"}},
+			Handler: app.handleManagerUserCommand,
+		},
+		{
+			Name:            "lang",
+			Aliases:         map[string][]string{"ru": {"язык"}, "en": {"lang", "language"}},
+			Handler:         app.handleLangCommand,
+			HasArgs:         true,
+			IsForModerators: true,
+		},
+	}
+
+	app.UserCommands = map[string]*userCommand{}
+	for _, command := range app.UserCommandsList {
+		for _, aliases := range command.Aliases {
+			for _, alias := range aliases {
+				app.UserCommands[alias] = command
+			}
+		}
+	}
+	return nil
+}
+
+// returns nil command if the message is not a command
+func (app *solution) findUserCommand(messageText string) (*userCommand, []string) {
+	parts := strings.Fields(strings.ToLower(messageText))
+	if len(parts) == 0 {
+		return nil, nil
+	}
+
+	command, isFound := app.UserCommands[parts[0]]
+	if !isFound || (!command.HasArgs && len(parts) > 1) {
+		return nil, nil
+	}
+	return command, parts[1:]
+}
+
+// returns aliases in user language first
+func (command *userCommand) getAliases(lang string) []string {
+	aliases := append([]string{}, command.Aliases[lang]...)
+	if lang != catalog.DefaultLang {
+		aliases = append(aliases, command.Aliases[catalog.DefaultLang]...)
+	}
+	if len(aliases) == 0 {
+		for _, langAliases := range command.Aliases {
+			aliases = append(aliases, langAliases...)
+		}
+	}
+	return aliases
+}
+
+func (app *solution) getHelpMessage(lang string) string {
+	msg := tr(lang, "user.help_title")
+	for _, command := range app.UserCommandsList {
+		msg += "\n\n" + strings.Join(command.getAliases(lang), " / ") + " - " + tr(lang, "cmd."+command.Name)
+	}
+	return msg
+}
+
+func (app *solution) handleHelpCommand(task userCommandTask) (string, error) {
+	return app.getHelpMessage(task.Lang), nil
+}
+
+func (app *solution) handleBalanceCommand(task userCommandTask) (string, error) {
+	return app.getUserBalance(task.Lang, task.User), nil
+}
+
+func getPointsKindName(lang, kind string) string {
+	return tr(lang, "points.kind_"+kind)
+}
+
+func (app *solution) handleHistoryCommand(task userCommandTask) (string, error) {
+	movements, err := app.DB.getPointsHistory(task.Pubkey, userHistoryLimit)
+	if err != nil {
+		return "", err
+	}
+	if len(movements) == 0 {
+		return tr(task.Lang, "user.history_empty"), nil
+	}
+
+	msg := tr(task.Lang, "user.history_title")
+	for _, m := range movements {
+		amount := formatFloat(m.Amount)
+		if m.Amount > 0 {
+			amount = "+" + amount
+		}
+		msg += "\n" + formatUnixTime(m.CreatedAt) + "  " + amount + "  " + getPointsKindName(task.Lang, m.Kind)
+	}
+	return msg, nil
+}
+
+// returns monday of the week
+func getWeekStart(t time.Time) time.Time {
+	daysFromMonday := (int(t.Weekday()) + 6) % 7
+	return t.AddDate(0, 0, -daysFromMonday)
+}
+
+func formatOnlineHours(minutes int) string {
+	return formatFloat(float64(minutes) / 60)
+}
+
+func (app *solution) handleStatsCommand(task userCommandTask) (string, error) {
+	now := app.getRewardsNow()
+	today := now.Format(journalLogsTimeFormat)
+	todayMinutes, err := app.DB.getOnlineMinutes(task.Pubkey, today)
+	if err != nil {
+		return "", err
+	}
+	weekMinutes, err := app.DB.getOnlineMinutes(task.Pubkey, getWeekStart(now).Format(journalLogsTimeFormat))
+	if err != nil {
+		return "", err
+	}
+	todayPoints, err := app.DB.getAccruedPoints(task.Pubkey, today)
+	if err != nil {
+		return "", err
+	}
+
+	return tr(task.Lang, "user.stats",
+		formatOnlineHours(todayMinutes), formatOnlineHours(weekMinutes), formatFloat(todayPoints),
+	), nil
+}
+
+func (app *solution) handleWithdrawCommand(task userCommandTask) (string, error) {
+	if task.User.Balance < app.Config.MinWithdraw {
+		return tr(task.Lang, "user.withdraw_not_enough",
+			formatFloat(app.Config.MinWithdraw), formatFloat(task.User.Balance),
+		), nil
+	}
+
+	if !app.isSupportEnabled() {
+		return tr(task.Lang, "user.manager", app.Config.RequestsModeratorPubkey, app.Config.ModeratorTelegram), nil
+	}
+	return app.openSupportTicket(task.Pubkey, task.Nick, task.Lang, tr(catalog.DefaultLang, "support.withdraw_request",
+		formatFloat(task.User.Balance), task.Pubkey, formatFloat(task.User.Balance),
+	)), nil
+}
+
+func (app *solution) handleRankCommand(task userCommandTask) (string, error) {
+	rank, total, err := app.DB.getUserRank(task.User.Balance)
+	if err != nil {
+		return "", err
+	}
+	return tr(task.Lang, "user.rank", rank, total, formatFloat(task.User.Balance)), nil
+}
+
+func (app *solution) handleManagerUserCommand(task userCommandTask) (string, error) {
+	return app.handleManagerCommand(task.Pubkey, task.Nick, task.Lang), nil
+}
+
+func (app *solution) handleLangCommand(task userCommandTask) (string, error) {
+	return app.handleLanguageCommand(task.Pubkey, task.Lang, task.Args), nil
+}
+
+// sends command reply to the user
+func (app *solution) runUserCommand(command *userCommand, task userCommandTask) {
+	reply, err := command.Handler(task)
+	if err != nil {
+		logger.Error(err)
+		reply = tr(task.Lang, "user.request_failed")
+	}
+
+	if err := app.sendMessage(task.Pubkey, reply); err != nil {
+		app.onUtopiaError(err)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFindUserCommand(t *testing.T) {
+	app := &solution{}
+	if err := app.setupUserCommands(); err != nil {
+		t.Fatal(err)
+	}
+
+	aliases := map[string]string{
+		"Баланс":    "balance",
+		" history ": "history",
+		"топ":       "rank",
+		"язык en":   "lang",
+		"вывести":   "withdraw",
+	}
+	for text, expected := range aliases {
+		command, _ := app.findUserCommand(text)
+		if command == nil || command.Name != expected {
+			t.Fatalf("expected command %q for %q, got %+v", expected, text, command)
+		}
+	}
+
+	command, args := app.findUserCommand("LANG En")
+	if command == nil || !reflect.DeepEqual(args, []string{"en"}) {
+		t.Fatalf("unexpected args: %v", args)
+	}
+
+	for _, text := range []string{"как вывести баллы?", "вывести все", "баланс не пришел"} {
+		if command, _ := app.findUserCommand(text); command != nil {
+			t.Fatalf("unexpected command %q for %q", command.Name, text)
+		}
+	}
+}
+
+func TestGetWeekStart(t *testing.T) {
+	sunday := time.Date(2022, 6, 5, 12, 0, 0, 0, time.UTC)
+	if day := getWeekStart(sunday).Format(journalLogsTimeFormat); day != "2022-05-30" {
+		t.Fatalf("expected monday 2022-05-30, got %s", day)
+	}
+
+	monday := time.Date(2022, 5, 30, 0, 0, 0, 0, time.UTC)
+	if !getWeekStart(monday).Equal(monday) {
+		t.Fatal("monday must be the week start")
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// voucher code format. template symbols:
+// X - random symbol, # - check symbol, others are copied as is
+type voucherFormat struct {
+	Prefix       string
+	Template     string
+	Regexp       *regexp.Regexp
+	LegacyRegexp *regexp.Regexp // codes generated before checksums: prefix + XX-XXXX-XXXX-XXXX
+}
+
+func newVoucherFormat(prefix, template string) (*voucherFormat, error) {
+	if template == "" {
+		template = defaultGameVoucherFormat
+	}
+	prefix = strings.ToUpper(prefix)
+	template = strings.ToUpper(template)
+
+	for _, r := range template {
+		if r > 127 {
+			return nil, errors.New("voucher format must contain only ASCII symbols")
+		}
+	}
+	if strings.Count(template, "#") != 1 {
+		return nil, errors.New("voucher format must contain one check symbol `#`")
+	}
+	if strings.Count(template, "X") < minVoucherRandomSymbols {
+		return nil, fmt.Errorf("voucher format must contain at least %v random symbols `X`", minVoucherRandomSymbols)
+	}
+
+	// any letter or digit, so typos outside of the alphabet are found too
+	symbolPattern := "[A-Z0-9]"
+	codePattern := regexp.QuoteMeta(prefix)
+	for _, r := range template {
+		if r == 'X' || r == '#' {
+			codePattern += symbolPattern
+		} else {
+			codePattern += regexp.QuoteMeta(string(r))
+		}
+	}
+
+	legacyPattern := regexp.QuoteMeta(prefix) + legacyVoucherPattern
+	return &voucherFormat{
+		Prefix:       prefix,
+		Template:     template,
+		Regexp:       regexp.MustCompile("(?:^|[^A-Z0-9])(" + codePattern + ")(?:[^A-Z0-9]|$)"),
+		LegacyRegexp: regexp.MustCompile("(?:^|[^A-Z0-9])(" + legacyPattern + ")(?:[^A-Z0-9]|$)"),
+	}, nil
+}
+
+func getRandomVoucherSymbol() (byte, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(len(voucherAlphabet))))
+	if err != nil {
+		return 0, fmt.Errorf("failed to get random number: %w", err)
+	}
+	return voucherAlphabet[i.Int64()], nil
+}
+
+// Luhn mod N algorithm, detects any single symbol typo & most of adjacent swaps
+func getVoucherCheckSymbol(symbols string) byte {
+	n := len(voucherAlphabet)
+	factor := 2
+	sum := 0
+	for i := len(symbols) - 1; i >= 0; i-- {
+		addend := factor * strings.IndexByte(voucherAlphabet, symbols[i])
+		factor = 3 - factor
+		sum += addend/n + addend%n
+	}
+	return voucherAlphabet[(n-sum%n)%n]
+}
+
+func (f *voucherFormat) generate() (string, error) {
+	code := []byte(f.Prefix + f.Template)
+	var symbols []byte
+	checkPos := 0
+
+	for i := len(f.Prefix); i < len(code); i++ {
+		switch code[i] {
+		case 'X':
+			symbol, err := getRandomVoucherSymbol()
+			if err != nil {
+				return "", err
+			}
+			code[i] = symbol
+			symbols = append(symbols, symbol)
+		case '#':
+			checkPos = i
+		}
+	}
+
+	code[checkPos] = getVoucherCheckSymbol(string(symbols))
+	return string(code), nil
+}
+
+// finds voucher code in the message text.
+// returns normalized code & true when found
+func (f *voucherFormat) find(messageText string) (string, bool) {
+	text := strings.ToUpper(strings.TrimSpace(messageText))
+	match := f.Regexp.FindStringSubmatch(text)
+	if match == nil {
+		match = f.LegacyRegexp.FindStringSubmatch(text)
+	}
+	if match == nil {
+		return "", false
+	}
+	return match[1], true
+}
+
+// legacy codes have no check symbol, they are checked in db only
+func (f *voucherFormat) isLegacy(code string) bool {
+	match := f.LegacyRegexp.FindStringSubmatch(code)
+	return match != nil && match[1] == code
+}
+
+// code must be normalized
+func (f *voucherFormat) isChecksumValid(code string) bool {
+	if len(code) != len(f.Prefix)+len(f.Template) {
+		return false
+	}
+
+	var symbols []byte
+	var checkSymbol byte
+	for i := 0; i < len(f.Template); i++ {
+		symbol := code[len(f.Prefix)+i]
+		switch f.Template[i] {
+		case 'X':
+			symbols = append(symbols, symbol)
+		case '#':
+			checkSymbol = symbol
+		default:
+			continue
+		}
+		if strings.IndexByte(voucherAlphabet, symbol) < 0 {
+			return false // look-alike symbol, such as O or 1
+		}
+	}
+	return getVoucherCheckSymbol(string(symbols)) == checkSymbol
+}
+
+func (app *solution) genGameVoucher() (string, error) {
+	return app.VoucherFormat.generate()
+}
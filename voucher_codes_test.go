@@ -0,0 +1,87 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVoucherCodes(t *testing.T) {
+	f, err := newVoucherFormat("UT-V", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := f.generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.isChecksumValid(code) {
+		t.Fatal("generated voucher should be valid: " + code)
+	}
+
+	found, isFound := f.find("  вот мой код: " + strings.ToLower(code) + ", спасибо ")
+	if !isFound || found != code {
+		t.Fatalf("voucher should be found in text, got %q", found)
+	}
+
+	if _, isFound := f.find("привет, как вывести баллы?"); isFound {
+		t.Fatal("regular message should not be detected as voucher")
+	}
+
+	// every single symbol typo should be detected
+	for i := len(f.Prefix); i < len(code); i++ {
+		if code[i] == '-' {
+			continue
+		}
+		for j := 0; j < len(voucherAlphabet); j++ {
+			if voucherAlphabet[j] == code[i] {
+				continue
+			}
+			typo := code[:i] + string(voucherAlphabet[j]) + code[i+1:]
+			if f.isChecksumValid(typo) {
+				t.Fatal("typo should be detected: " + typo)
+			}
+		}
+	}
+}
+
+func TestVoucherFormatValidation(t *testing.T) {
+	if _, err := newVoucherFormat("", "XXXX-XXXX"); err == nil {
+		t.Fatal("format without check symbol should be rejected")
+	}
+	if _, err := newVoucherFormat("", "XX#"); err == nil {
+		t.Fatal("format with few random symbols should be rejected")
+	}
+}
+
+func TestLegacyVoucherCodes(t *testing.T) {
+	f, err := newVoucherFormat("UT-V", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// generated by swiss-knife before checksums, look-alike symbols included
+	legacy := "UT-VA0-1bcO-XY9Z-QWER"
+	code, isFound := f.find("ваучер " + legacy)
+	if !isFound || code != strings.ToUpper(legacy) {
+		t.Fatalf("legacy voucher should be found, got %q", code)
+	}
+	if !f.isLegacy(code) {
+		t.Fatal("legacy voucher should be recognized: " + code)
+	}
+
+	generated, err := f.generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.isLegacy(generated) {
+		t.Fatal("new voucher should not be legacy: " + generated)
+	}
+
+	// typo with a symbol outside of the alphabet
+	typo := generated[:len(f.Prefix)] + "O" + generated[len(f.Prefix)+1:]
+	found, isFound := f.find(typo)
+	if !isFound || f.isChecksumValid(found) {
+		t.Fatal("typo should be found and rejected by checksum: " + typo)
+	}
+}
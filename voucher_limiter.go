@@ -0,0 +1,87 @@
+package main
+
+import (
+	"time"
+
+	"github.com/google/logger"
+)
+
+// failed voucher activation attempts by user
+type voucherAttempts struct {
+	Pubkey      string
+	Failed      int   // failed attempts since last lockout
+	Lockouts    int   // lockouts in a row
+	LockedUntil int64 // unix timestamp
+	LastFailure int64 // unix timestamp
+}
+
+func (a *voucherAttempts) isLocked(now time.Time) bool {
+	return a.LockedUntil > now.Unix()
+}
+
+func (a *voucherAttempts) getLockRemaining(now time.Time) time.Duration {
+	return time.Unix(a.LockedUntil, 0).Sub(now).Round(time.Second)
+}
+
+// returns true when user was locked after this attempt
+func (a *voucherAttempts) registerFailure(now time.Time) bool {
+	sinceLastFailure := now.Sub(time.Unix(a.LastFailure, 0))
+	if sinceLastFailure > voucherLockoutsResetTimeout {
+		a.Lockouts = 0
+	}
+	if sinceLastFailure > voucherFailedAttemptsWindow {
+		a.Failed = 0
+	}
+
+	a.Failed++
+	a.LastFailure = now.Unix()
+	if a.Failed < voucherMaxFailedAttempts {
+		return false
+	}
+
+	// each lockout in a row doubles the timeout
+	lockout := gameVoucherActivateTimeout << a.Lockouts
+	if lockout > voucherLockoutMax || lockout <= 0 {
+		lockout = voucherLockoutMax
+	}
+
+	a.Failed = 0
+	a.Lockouts++
+	a.LockedUntil = now.Add(lockout).Unix()
+	return true
+}
+
+func (a *voucherAttempts) registerSuccess() {
+	a.Failed = 0
+}
+
+func (app *solution) onVoucherActivationFailed(attempts *voucherAttempts, nickname string) {
+	isLocked := attempts.registerFailure(time.Now())
+	if err := app.DB.saveVoucherAttempts(attempts); err != nil {
+		logger.Error(err)
+	}
+
+	if !isLocked {
+		return
+	}
+
+	logger.Warning("user " + attempts.Pubkey + " locked for voucher activation")
+	if attempts.Lockouts < voucherBruteforceAlertLockouts {
+		return
+	}
+
+	app.notifyModerators(tr(catalog.DefaultLang, "vouchers.bruteforce",
+		nickname, attempts.Pubkey, attempts.Lockouts, formatUnixTime(attempts.LockedUntil),
+	))
+}
+
+func (app *solution) onVoucherActivated(attempts *voucherAttempts) {
+	if attempts.Failed == 0 {
+		return
+	}
+
+	attempts.registerSuccess()
+	if err := app.DB.saveVoucherAttempts(attempts); err != nil {
+		logger.Error(err)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVoucherAttemptsLockout(t *testing.T) {
+	now := time.Now()
+	a := voucherAttempts{Pubkey: "test"}
+
+	for i := 1; i < voucherMaxFailedAttempts; i++ {
+		if a.registerFailure(now) {
+			t.Fatalf("user should not be locked after %v attempts", i)
+		}
+	}
+	if !a.registerFailure(now) {
+		t.Fatal("user should be locked")
+	}
+	if !a.isLocked(now) {
+		t.Fatal("user should be locked now")
+	}
+	firstLockout := a.getLockRemaining(now)
+
+	// next lockout in a row should be longer
+	now = time.Unix(a.LockedUntil, 0).Add(time.Second)
+	for i := 0; i < voucherMaxFailedAttempts; i++ {
+		a.registerFailure(now)
+	}
+	if a.getLockRemaining(now) <= firstLockout {
+		t.Fatal("lockout should escalate")
+	}
+
+	// lockouts are forgotten after a long quiet period
+	now = now.Add(voucherLockoutsResetTimeout * 2)
+	a.registerFailure(now)
+	if a.Lockouts != 0 || a.Failed != 1 {
+		t.Fatalf("attempts should be reset, got %+v", a)
+	}
+}
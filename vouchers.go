@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tb "github.com/Sagleft/telegobot"
+)
+
+var (
+	errVoucherExpired   = errors.New("voucher expired")
+	errVoucherUserLimit = errors.New("voucher per user limit reached")
+	errVoucherNotFound  = errors.New("voucher not found")
+	errVoucherBudget    = errors.New("daily points budget is spent")
+)
+
+type voucherBatch struct {
+	ID           int64   `json:"id"`
+	Amount       float64 `json:"amount"`
+	CodesCount   int     `json:"codes_count"`
+	MaxUses      int     `json:"max_uses"`       // max redemptions per code
+	PerUserLimit int     `json:"per_user_limit"` // max redemptions per user in batch. 0 - unlimited
+	ExpiresAt    int64   `json:"expires_at"`     // unix timestamp. 0 - never
+	CreatedAt    int64   `json:"created_at"`     // unix timestamp
+	Revoked      bool    `json:"revoked"`
+}
+
+type voucherBatchStats struct {
+	Redemptions    int
+	UniqueUsers    int
+	CodesUsed      int
+	PointsIssued   float64
+	LastRedemption int64 // unix timestamp
+}
+
+func (b voucherBatch) isExpired() bool {
+	return b.ExpiresAt > 0 && time.Now().Unix() > b.ExpiresAt
+}
+
+func formatUnixTime(timestamp int64) string {
+	if timestamp == 0 {
+		return "-"
+	}
+	return time.Unix(timestamp, 0).Format(voucherTimeFormat)
+}
+
+// args: <count> <amount> <expiry> <max uses> <per user limit>
+func parseVoucherBatchArgs(args []string) (voucherBatch, int, error) {
+	batch := voucherBatch{
+		CreatedAt: time.Now().Unix(),
+	}
+	if len(args) < 5 {
+		return batch, 0, errors.New("not enough arguments")
+	}
+
+	count, err := strconv.Atoi(args[0])
+	if err != nil || count <= 0 || count > maxVoucherBatchSize {
+		return batch, 0, fmt.Errorf("codes count must be from 1 to %v", maxVoucherBatchSize)
+	}
+
+	batch.Amount, err = strconv.ParseFloat(args[1], 64)
+	if err != nil {
+		return batch, 0, fmt.Errorf("parse amount: %w", err)
+	}
+	if batch.Amount <= 0 || batch.Amount > maxGameVoucherAmount {
+		return batch, 0, fmt.Errorf("voucher amount must be from 0 to %v", maxGameVoucherAmount)
+	}
+
+	batch.ExpiresAt, err = parseExpiryTime(args[2])
+	if err != nil {
+		return batch, 0, err
+	}
+
+	batch.MaxUses, err = strconv.Atoi(args[3])
+	if err != nil || batch.MaxUses <= 0 {
+		return batch, 0, errors.New("max uses per code must be positive")
+	}
+
+	batch.PerUserLimit, err = strconv.Atoi(args[4])
+	if err != nil || batch.PerUserLimit < 0 {
+		return batch, 0, errors.New("per user limit must be 0 or positive")
+	}
+	return batch, count, nil
+}
+
+func (app *solution) handleCreateVoucherBatchRequest(
+	lang string, args []string, fromTelegram bool, telegramUserID int64,
+) ([]string, error) {
+	batch, count, err := parseVoucherBatchArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		code, err := app.genGameVoucher()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+
+	if err := app.DB.saveVoucherBatch(&batch, codes); err != nil {
+		return nil, err
+	}
+
+	msg := tr(lang, "mod.batch_created", batch.ID, count, formatFloat(batch.Amount))
+
+	if !fromTelegram {
+		// no files in utopia chat, send codes as text
+		return []string{msg, strings.Join(codes, "\n")}, nil
+	}
+
+	csv := getVoucherBatchCSV(batch, codes)
+	_, err = app.TelegramBot.Send(tb.ChatID(telegramUserID), &tb.Document{
+		File:     tb.FromReader(bytes.NewReader([]byte(csv))),
+		MIME:     "text/csv",
+		FileName: "vouchers_" + strconv.FormatInt(batch.ID, 10) + ".csv",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("batch created, but failed to send csv: %w", err)
+	}
+	return []string{msg}, nil
+}
+
+func getVoucherBatchCSV(batch voucherBatch, codes []string) string {
+	csv := "code, amount, max uses, per user limit, expires at"
+	for _, code := range codes {
+		csv += "\n" + code + ", " + formatFloat(batch.Amount) + ", " +
+			strconv.Itoa(batch.MaxUses) + ", " + strconv.Itoa(batch.PerUserLimit) + ", " +
+			formatUnixTime(batch.ExpiresAt)
+	}
+	return csv
+}
+
+func (app *solution) handleVoucherBatchesList(lang string) ([]string, error) {
+	batches, err := app.DB.getVoucherBatches(voucherBatchesListLimit)
+	if err != nil {
+		return nil, err
+	}
+	if len(batches) == 0 {
+		return []string{tr(lang, "mod.batches_empty")}, nil
+	}
+
+	msg := tr(lang, "mod.batches_title")
+	for _, b := range batches {
+		msg += tr(lang, "mod.batches_item", b.ID, b.CodesCount, formatFloat(b.Amount),
+			b.MaxUses, b.PerUserLimit, formatUnixTime(b.ExpiresAt))
+
+		if b.Revoked {
+			msg += tr(lang, "mod.batch_revoked_tag")
+		} else if b.isExpired() {
+			msg += tr(lang, "mod.batch_expired_tag")
+		}
+	}
+	return []string{msg}, nil
+}
+
+func parseVoucherBatchID(raw string) (int64, error) {
+	batchID, err := strconv.ParseInt(strings.TrimPrefix(raw, "#"), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse batch ID: %w", err)
+	}
+	return batchID, nil
+}
+
+func (app *solution) handleVoucherBatchRevoke(lang, batchIDRaw string) ([]string, error) {
+	batchID, err := parseVoucherBatchID(batchIDRaw)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := app.DB.revokeVoucherBatch(batchID); err != nil {
+		return nil, err
+	}
+	return []string{tr(lang, "mod.batch_revoked", batchID)}, nil
+}
+
+func (app *solution) handleVoucherBatchStats(lang, batchIDRaw string) ([]string, error) {
+	batchID, err := parseVoucherBatchID(batchIDRaw)
+	if err != nil {
+		return nil, err
+	}
+
+	batch, err := app.DB.getVoucherBatch(batchID)
+	if err != nil {
+		return nil, err
+	}
+	if batch == nil {
+		return []string{tr(lang, "mod.batch_not_found")}, nil
+	}
+
+	stats, err := app.DB.getVoucherBatchStats(batchID)
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{tr(lang, "mod.batch_stats",
+		batch.ID,
+		stats.CodesUsed, batch.CodesCount,
+		stats.Redemptions, batch.CodesCount*batch.MaxUses,
+		stats.UniqueUsers,
+		formatFloat(stats.PointsIssued),
+		formatUnixTime(stats.LastRedemption),
+	)}, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseVoucherBatchArgs(t *testing.T) {
+	batch, count, err := parseVoucherBatchArgs([]string{"100", "25.5", "3d", "2", "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 100 || batch.Amount != 25.5 || batch.MaxUses != 2 || batch.PerUserLimit != 1 {
+		t.Fatalf("unexpected batch: %v codes, %+v", count, batch)
+	}
+	expiresAt := time.Now().Add(3 * 24 * time.Hour).Unix()
+	if batch.ExpiresAt < expiresAt-5 || batch.ExpiresAt > expiresAt {
+		t.Fatalf("unexpected expiry: %v", batch.ExpiresAt)
+	}
+
+	batch, _, err = parseVoucherBatchArgs([]string{"1", "10", "-", "1", "0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batch.ExpiresAt != 0 || batch.PerUserLimit != 0 {
+		t.Fatalf("expected batch without expiry & user limit, got %+v", batch)
+	}
+
+	invalid := [][]string{
+		{"10", "5", "1d", "1"},
+		{"0", "5", "1d", "1", "0"},
+		{"1001", "5", "1d", "1", "0"},
+		{"10", "abc", "1d", "1", "0"},
+		{"10", "0", "1d", "1", "0"},
+		{"10", "1001", "1d", "1", "0"},
+		{"10", "5", "tomorrow", "1", "0"},
+		{"10", "5", "1d", "0", "0"},
+		{"10", "5", "1d", "1", "-1"},
+	}
+	for _, args := range invalid {
+		if _, _, err := parseVoucherBatchArgs(args); err == nil {
+			t.Fatalf("expected error for %v", args)
+		}
+	}
+}
+
+func TestGetVoucherBatchCSV(t *testing.T) {
+	batch := voucherBatch{Amount: 12.5, MaxUses: 3, PerUserLimit: 1}
+	csv := getVoucherBatchCSV(batch, []string{"T2E-AAAA", "T2E-BBBB"})
+
+	lines := strings.Split(csv, "\n")
+	expected := []string{
+		"code, amount, max uses, per user limit, expires at",
+		"T2E-AAAA, " + formatFloat(12.5) + ", 3, 1, -",
+		"T2E-BBBB, " + formatFloat(12.5) + ", 3, 1, -",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("unexpected csv:\n%s", csv)
+	}
+
+	batch.ExpiresAt = time.Date(2022, 7, 1, 12, 0, 0, 0, time.Local).Unix()
+	csv = getVoucherBatchCSV(batch, []string{"T2E-AAAA"})
+	if !strings.HasSuffix(csv, ", "+formatUnixTime(batch.ExpiresAt)) {
+		t.Fatalf("expected expiry in csv, got:\n%s", csv)
+	}
+}